@@ -0,0 +1,676 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"text/tabwriter"
+
+	"github.com/celestiaorg/talis-test/config"
+	"github.com/celestiaorg/talis-test/manager"
+	"github.com/spf13/cobra"
+)
+
+// newUpCmd creates the command that runs the complete deployment pipeline
+func newUpCmd(opts *rootOptions) *cobra.Command {
+	var chainID string
+
+	cmd := &cobra.Command{
+		Use:   "up",
+		Short: "Create infrastructure, install tools, generate genesis and start the network",
+		Long: `Runs every stage of a deployment in order: creates the instances with Talis,
+installs Go, Celestia App and Celestia Node, generates and distributes the
+genesis and finally starts the Celestia App services, the bridge nodes and
+the light nodes.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mgr, cfg, err := opts.newLockedManager()
+			if err != nil {
+				return err
+			}
+			defer mgr.Close()
+			if chainID != "" {
+				cfg.ChainID = chainID
+			}
+			ctx := cmd.Context()
+
+			log.Println("Preparing infrastructure and installing tools...")
+			if err := mgr.Run(ctx); err != nil {
+				return err
+			}
+
+			log.Println("Setting up Celestia network...")
+			if err := mgr.SetupCelestiaNetwork(ctx, cfg.ChainID, false); err != nil {
+				return fmt.Errorf("failed to set up Celestia network: %w", err)
+			}
+
+			log.Println("Starting Celestia App service on configured instances...")
+			if err := mgr.SetupCelestiaAppService(ctx); err != nil {
+				return fmt.Errorf("failed to start Celestia App service: %w", err)
+			}
+
+			log.Println("Starting bridge nodes...")
+			if err := mgr.SetupBridgeNodes(ctx, cfg.ChainID); err != nil {
+				return fmt.Errorf("failed to set up bridge nodes: %w", err)
+			}
+
+			log.Println("Starting light nodes...")
+			if err := mgr.SetupLightNodes(ctx, cfg.ChainID); err != nil {
+				return fmt.Errorf("failed to set up light nodes: %w", err)
+			}
+
+			log.Println("Deployment completed successfully")
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&chainID, "chain-id", "", "Chain ID for the Celestia network (overrides the manifest)")
+
+	return cmd
+}
+
+// newInfraCmd creates the command that provisions the instances
+func newInfraCmd(opts *rootOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "infra",
+		Short: "Create infrastructure (servers with Talis)",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mgr, _, err := opts.newLockedManager()
+			if err != nil {
+				return err
+			}
+			defer mgr.Close()
+
+			log.Println("Preparing infrastructure...")
+			if err := mgr.PrepareInfrastructure(cmd.Context()); err != nil {
+				return fmt.Errorf("failed to prepare infrastructure: %w", err)
+			}
+			log.Println("Infrastructure preparation completed successfully")
+			return nil
+		},
+	}
+}
+
+// newInstallCmd creates the command that installs the required tools
+func newInstallCmd(opts *rootOptions) *cobra.Command {
+	var skipGo, skipApp, skipNode bool
+
+	cmd := &cobra.Command{
+		Use:   "install",
+		Short: "Install required tools (Go, Celestia App, Celestia Node)",
+		Long: `Installs Go on every instance and Celestia App and Celestia Node on the
+instances whose node type requires them. Already installed tools are skipped.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mgr, _, err := opts.newLockedManager()
+			if err != nil {
+				return err
+			}
+			defer mgr.Close()
+			ctx := cmd.Context()
+
+			if !skipGo {
+				log.Println("Installing Go on instances...")
+				if err := mgr.InstallGoOnInstances(ctx); err != nil {
+					return fmt.Errorf("failed to install Go on instances: %w", err)
+				}
+				log.Println("Go installation completed successfully")
+			}
+
+			if !skipApp {
+				log.Println("Installing Celestia App on configured instances...")
+				if err := mgr.InstallCelestiaAppOnInstances(ctx); err != nil {
+					return fmt.Errorf("failed to install Celestia App on instances: %w", err)
+				}
+				log.Println("Celestia App installation completed successfully")
+			}
+
+			if !skipNode {
+				log.Println("Installing Celestia Node on configured instances...")
+				if err := mgr.InstallCelestiaNodeOnInstances(ctx); err != nil {
+					return fmt.Errorf("failed to install Celestia Node on instances: %w", err)
+				}
+				log.Println("Celestia Node installation completed successfully")
+			}
+
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&skipGo, "skip-go", false, "Do not install Go")
+	cmd.Flags().BoolVar(&skipApp, "skip-app", false, "Do not install Celestia App")
+	cmd.Flags().BoolVar(&skipNode, "skip-node", false, "Do not install Celestia Node")
+
+	return cmd
+}
+
+// newGenesisCmd creates the command that generates and distributes the genesis
+func newGenesisCmd(opts *rootOptions) *cobra.Command {
+	var (
+		chainID    string
+		rotateKeys bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "genesis",
+		Short: "Create and distribute the genesis, keys and node configuration",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mgr, cfg, err := opts.newLockedManager()
+			if err != nil {
+				return err
+			}
+			defer mgr.Close()
+			if chainID != "" {
+				cfg.ChainID = chainID
+			}
+
+			log.Println("Setting up Celestia network...")
+			if err := mgr.SetupCelestiaNetwork(cmd.Context(), cfg.ChainID, rotateKeys); err != nil {
+				return fmt.Errorf("failed to set up Celestia network: %w", err)
+			}
+			log.Println("Celestia network setup completed successfully")
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&chainID, "chain-id", "", "Chain ID for the Celestia network (overrides the manifest)")
+	cmd.Flags().BoolVar(&rotateKeys, "rotate-keys", false, "Replace the stored node keys with newly generated ones")
+
+	return cmd
+}
+
+// newStartCmd creates the command that starts the Celestia App services
+func newStartCmd(opts *rootOptions) *cobra.Command {
+	var chainID string
+
+	cmd := &cobra.Command{
+		Use:   "start",
+		Short: "Start the Celestia App, bridge node and light node services",
+		Long: `Starts the Celestia App service on the consensus nodes, then initializes
+and starts the bridge nodes, each connected to one of the validators, and
+finally the light nodes, which use the bridges as bootstrappers.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mgr, cfg, err := opts.newLockedManager()
+			if err != nil {
+				return err
+			}
+			defer mgr.Close()
+			if chainID != "" {
+				cfg.ChainID = chainID
+			}
+			ctx := cmd.Context()
+
+			log.Println("Starting Celestia App service on configured instances...")
+			if err := mgr.SetupCelestiaAppService(ctx); err != nil {
+				return fmt.Errorf("failed to start Celestia App service: %w", err)
+			}
+			log.Println("Celestia App service started successfully")
+
+			log.Println("Starting bridge nodes...")
+			if err := mgr.SetupBridgeNodes(ctx, cfg.ChainID); err != nil {
+				return fmt.Errorf("failed to set up bridge nodes: %w", err)
+			}
+			log.Println("Bridge nodes started successfully")
+
+			log.Println("Starting light nodes...")
+			if err := mgr.SetupLightNodes(ctx, cfg.ChainID); err != nil {
+				return fmt.Errorf("failed to set up light nodes: %w", err)
+			}
+			log.Println("Light nodes started successfully")
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&chainID, "chain-id", "", "Chain ID for the Celestia network (overrides the manifest)")
+
+	return cmd
+}
+
+// newScaleOutCmd creates the command that adds validators to the running network
+func newScaleOutCmd(opts *rootOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "scale-out",
+		Short: "Add the new validators of the manifest to the running network",
+		Long: `Creates the validator instances that were added to the manifest and installs
+their tools. Each new validator then receives the existing genesis and the
+validators as peers, is started, funded from the account of a genesis
+validator once it caught up, and bonds with a create-validator transaction.
+The genesis is not regenerated.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mgr, _, err := opts.newLockedManager()
+			if err != nil {
+				return err
+			}
+			defer mgr.Close()
+			ctx := cmd.Context()
+
+			log.Println("Preparing infrastructure and installing tools...")
+			if err := mgr.Run(ctx); err != nil {
+				return err
+			}
+
+			log.Println("Adding validators to the network...")
+			if err := mgr.JoinValidators(ctx); err != nil {
+				return fmt.Errorf("failed to add validators: %w", err)
+			}
+			log.Println("Scale-out completed successfully")
+			return nil
+		},
+	}
+}
+
+// newStopCmd creates the command that stops the Celestia App services
+func newStopCmd(opts *rootOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "stop",
+		Short: "Stop the light node, bridge node and Celestia App services",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mgr, _, err := opts.newLockedManager()
+			if err != nil {
+				return err
+			}
+			defer mgr.Close()
+			ctx := cmd.Context()
+
+			log.Println("Stopping light nodes...")
+			if err := mgr.StopLightNodes(ctx); err != nil {
+				return fmt.Errorf("failed to stop light nodes: %w", err)
+			}
+
+			log.Println("Stopping bridge nodes...")
+			if err := mgr.StopBridgeNodes(ctx); err != nil {
+				return fmt.Errorf("failed to stop bridge nodes: %w", err)
+			}
+
+			log.Println("Stopping Celestia App service on configured instances...")
+			if err := mgr.StopCelestiaAppService(ctx); err != nil {
+				return fmt.Errorf("failed to stop Celestia App service: %w", err)
+			}
+			log.Println("Celestia App service stopped successfully")
+			return nil
+		},
+	}
+}
+
+// newStatusCmd creates the command that reports the health of every node
+func newStatusCmd(opts *rootOptions) *cobra.Command {
+	var jsonOutput bool
+
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show the service state and chain health of every node",
+		Long: `Queries every instance recorded in state: the state of its systemd service
+and, for validators and full nodes, the latest height, catching_up flag, peer
+count and app version reported by the CometBFT RPC. Light nodes additionally
+report their data availability sampling progress.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mgr, cfg, err := opts.newManager()
+			if err != nil {
+				return err
+			}
+			defer mgr.Close()
+			ctx := cmd.Context()
+
+			statuses, err := mgr.Status(ctx)
+			if err != nil {
+				return err
+			}
+
+			samplingStatuses, err := mgr.LightNodeStatus(ctx)
+			if err != nil {
+				return err
+			}
+
+			if jsonOutput {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(struct {
+					Nodes      []manager.NodeStatus     `json:"nodes"`
+					LightNodes []manager.SamplingStatus `json:"light_nodes"`
+				}{
+					Nodes:      statuses,
+					LightNodes: samplingStatuses,
+				})
+			}
+
+			if len(statuses) == 0 {
+				fmt.Printf("No instances found for project %s\n", cfg.ProjectName)
+				return nil
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "NAME\tPUBLIC IP\tROLE\tSERVICE\tHEIGHT\tCATCHING UP\tPEERS\tAPP VERSION\tERROR")
+			for _, s := range statuses {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%t\t%d\t%d\t%s\n",
+					s.Name, s.PublicIP, s.Role, s.ServiceState, s.LatestHeight, s.CatchingUp, s.Peers, s.AppVersion, s.Error)
+			}
+			if err := w.Flush(); err != nil {
+				return err
+			}
+
+			if len(samplingStatuses) == 0 {
+				return nil
+			}
+
+			fmt.Println()
+			w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "LIGHT NODE\tPUBLIC IP\tSAMPLED HEAD\tCATCHUP HEAD\tNETWORK HEAD\tCATCH-UP DONE\tRUNNING\tERROR")
+			for _, s := range samplingStatuses {
+				fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%t\t%t\t%s\n",
+					s.Name, s.PublicIP, s.HeadOfSampledChain, s.HeadOfCatchup, s.NetworkHeadHeight, s.CatchUpDone, s.IsRunning, s.Error)
+			}
+			return w.Flush()
+		},
+	}
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Print the status as JSON")
+
+	return cmd
+}
+
+// newPlanCmd creates the command that shows the changes apply would make
+func newPlanCmd(opts *rootOptions) *cobra.Command {
+	var jsonOutput bool
+
+	cmd := &cobra.Command{
+		Use:   "plan",
+		Short: "Show the instance changes needed to match the manifest",
+		Long: `Compares the instances of the manifest with the instances recorded in state and
+the instances of the Talis project. Lists the instances to create, delete or
+replace because their role, region or size changed, and the drift between state
+and Talis: instances that are gone, instances state lost track of, and changed
+public IPs.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mgr, _, err := opts.newManager()
+			if err != nil {
+				return err
+			}
+			defer mgr.Close()
+
+			plan, err := mgr.Plan(cmd.Context())
+			if err != nil {
+				return fmt.Errorf("failed to plan: %w", err)
+			}
+
+			if jsonOutput {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(plan)
+			}
+			return printPlan(plan)
+		},
+	}
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Print the plan as JSON")
+
+	return cmd
+}
+
+// newApplyCmd creates the command that makes the changes of the plan
+func newApplyCmd(opts *rootOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "apply",
+		Short: "Create, delete and replace instances to match the manifest",
+		Long: `Computes the plan and executes only its changes, then waits for new instances
+to be ready. Instances are not installed or added to the network; run the
+install, genesis and start stages for that.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mgr, _, err := opts.newLockedManager()
+			if err != nil {
+				return err
+			}
+			defer mgr.Close()
+			ctx := cmd.Context()
+
+			plan, err := mgr.Plan(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to plan: %w", err)
+			}
+			if err := printPlan(plan); err != nil {
+				return err
+			}
+
+			if err := mgr.Apply(ctx, plan); err != nil {
+				return fmt.Errorf("failed to apply: %w", err)
+			}
+			log.Println("Apply completed successfully")
+			return nil
+		},
+	}
+}
+
+// printPlan prints the actions of a plan as a table
+func printPlan(plan *manager.Plan) error {
+	if plan.Empty() {
+		fmt.Println("No changes, the deployment matches the manifest")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ACTION\tINSTANCE\tREASON")
+	for _, action := range plan.Actions {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", action.Kind, action.Name, action.Reason)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("\nPlan: %s\n", plan.Summary())
+	return nil
+}
+
+// newDestroyCmd creates the command that deletes all instances
+func newDestroyCmd(opts *rootOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "destroy",
+		Short: "Delete all deployed instances",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mgr, _, err := opts.newLockedManager()
+			if err != nil {
+				return err
+			}
+			defer mgr.Close()
+
+			log.Println("Deleting all instances...")
+			if err := mgr.DeleteAllInstances(cmd.Context()); err != nil {
+				return fmt.Errorf("failed to delete instances: %w", err)
+			}
+			log.Println("Instance deletion completed successfully")
+			return nil
+		},
+	}
+}
+
+// newSSHCmd creates the command that opens an SSH session to an instance
+func newSSHCmd(opts *rootOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "ssh <instance> [command...]",
+		Short: "Open an SSH session on an instance or run a command on it",
+		Long: `Connects to the named instance with the system ssh client using the SSH
+settings from the manifest. Without a command an interactive shell is opened.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mgr, cfg, err := opts.newManager()
+			if err != nil {
+				return err
+			}
+			defer mgr.Close()
+
+			instances, err := mgr.Instances()
+			if err != nil {
+				return err
+			}
+
+			var target *manager.InstanceInfo
+			for i := range instances {
+				if instances[i].Name == args[0] {
+					target = &instances[i]
+					break
+				}
+			}
+			if target == nil {
+				return fmt.Errorf("instance %s not found", args[0])
+			}
+			if target.PublicIP == "" {
+				return fmt.Errorf("instance %s has no public IP", target.Name)
+			}
+
+			knownHostsPath, err := manager.KnownHostsPath()
+			if err != nil {
+				return err
+			}
+
+			// Share the host keys recorded by the manager
+			sshArgs := []string{
+				"-i", cfg.SSHPrivateKeyPath,
+				"-o", "UserKnownHostsFile=" + knownHostsPath,
+				"-o", "StrictHostKeyChecking=accept-new",
+				fmt.Sprintf("%s@%s", cfg.SSHUsername, target.PublicIP),
+			}
+			sshArgs = append(sshArgs, args[1:]...)
+
+			sshCmd := exec.CommandContext(cmd.Context(), "ssh", sshArgs...)
+			sshCmd.Stdin = os.Stdin
+			sshCmd.Stdout = os.Stdout
+			sshCmd.Stderr = os.Stderr
+			return sshCmd.Run()
+		},
+	}
+}
+
+// newTrustCmd creates the command that re-trusts the host key of an instance
+func newTrustCmd(opts *rootOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "trust <instance>",
+		Short: "Accept the current host key of a rebuilt instance",
+		Long: `Host keys are recorded on the first connection to an instance and verified on
+every later connection. When an instance was rebuilt and presents a new key,
+this command replaces the recorded key with the one the instance presents now.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mgr, _, err := opts.newManager()
+			if err != nil {
+				return err
+			}
+			defer mgr.Close()
+
+			if err := mgr.TrustInstance(cmd.Context(), args[0]); err != nil {
+				return fmt.Errorf("failed to trust instance %s: %w", args[0], err)
+			}
+			log.Printf("Trusted the host key of instance %s", args[0])
+			return nil
+		},
+	}
+}
+
+// newEnvCmd creates the command group that manages environments
+func newEnvCmd(opts *rootOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "env",
+		Short: "List, select and destroy environments",
+		Long: `An environment is an independent deployment with its own state, Talis project,
+chain ID and instance name prefix, so that several networks can run side by
+side. Every command operates on the environment given with --env, or else on
+the one selected with env use. The default environment uses the project, chain
+ID and instance names of the manifest unchanged.`,
+	}
+
+	cmd.AddCommand(
+		newEnvListCmd(),
+		newEnvUseCmd(),
+		newEnvDestroyCmd(opts),
+	)
+
+	return cmd
+}
+
+// newEnvListCmd creates the command that lists the environments
+func newEnvListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List the environments that have state",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			envs, err := manager.ListEnvironments()
+			if err != nil {
+				return err
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "CURRENT\tNAME\tINSTANCES")
+			for _, env := range envs {
+				current := ""
+				if env.Current {
+					current = "*"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%d\n", current, env.Name, env.Instances)
+			}
+			return w.Flush()
+		},
+	}
+}
+
+// newEnvUseCmd creates the command that selects the current environment
+func newEnvUseCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "use <name>",
+		Short: "Select the environment used by the other commands",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := manager.UseEnvironment(args[0]); err != nil {
+				return fmt.Errorf("failed to select environment %s: %w", args[0], err)
+			}
+			log.Printf("Using environment %s", args[0])
+			return nil
+		},
+	}
+}
+
+// newEnvDestroyCmd creates the command that deletes an environment with its instances
+func newEnvDestroyCmd(opts *rootOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "destroy <name>",
+		Short: "Delete the instances and the state of an environment",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			env := args[0]
+			mgr, _, err := opts.newLockedEnvironmentManager(env)
+			if err != nil {
+				return err
+			}
+			defer mgr.Close()
+
+			instances, err := mgr.Instances()
+			if err != nil {
+				return err
+			}
+			if len(instances) > 0 {
+				log.Printf("Deleting %d instances of environment %s...", len(instances), env)
+				if err := mgr.DeleteAllInstances(cmd.Context()); err != nil {
+					return fmt.Errorf("failed to delete instances: %w", err)
+				}
+			}
+			if err := mgr.RemoveEnvironmentState(); err != nil {
+				return fmt.Errorf("failed to remove state of environment %s: %w", env, err)
+			}
+
+			// Fall back to the default environment if the current one is gone
+			current, err := manager.CurrentEnvironment()
+			if err != nil {
+				return err
+			}
+			if current == env {
+				if err := manager.UseEnvironment(config.DefaultEnvironment); err != nil {
+					return err
+				}
+			}
+
+			log.Printf("Environment %s destroyed", env)
+			return nil
+		},
+	}
+}
@@ -1,15 +1,18 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/celestiaorg/talis/pkg/db/models"
 )
 
-// expandPath expands $HOME and ~ in the given path
-func expandPath(path string) string {
+// ExpandPath expands $HOME and ~ in the given path
+func ExpandPath(path string) string {
 	if strings.HasPrefix(path, "~") {
 		home, err := os.UserHomeDir()
 		if err != nil {
@@ -32,39 +35,195 @@ type Config struct {
 	Username            string
 	ProjectName         string
 	ProjectDescription  string
+	ChainID             string
 	Instances           []InstanceDefinition
 	SSHUsername         string
 	SSHPrivateKeyPath   string
 	GoVersion           string
 	CelestiaAppVersion  string
 	CelestiaNodeVersion string
+	Timeouts            Timeouts
+	Genesis             GenesisParams
+	Accounts            []AccountConfig
+	// KeySeed makes the generated node keys reproducible, nil generates
+	// random keys
+	KeySeed *int64
+	// Environment is the name of the environment the configuration is scoped to
+	Environment string
+}
+
+// DefaultEnvironment is the environment used when none is selected. It uses
+// the project, chain ID and instance names of the manifest unchanged.
+const DefaultEnvironment = "default"
+
+// environmentNamePattern restricts environment names to what is valid in
+// instance hostnames and chain IDs
+var environmentNamePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,30}[a-z0-9])?$`)
+
+// ValidateEnvironmentName checks that name can be used as an environment name
+func ValidateEnvironmentName(name string) error {
+	if !environmentNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid environment name %q: use up to 32 lowercase letters, digits and dashes", name)
+	}
+	return nil
+}
+
+// WithEnvironment scopes the configuration to the named environment. Other
+// environments than the default one get their own project, chain ID and
+// instance name prefix so that they can run side by side.
+func (c Config) WithEnvironment(name string) Config {
+	c.Environment = name
+	if name == DefaultEnvironment {
+		return c
+	}
+
+	c.ProjectName = c.ProjectName + "-" + name
+	c.ChainID = c.ChainID + "-" + name
+	instances := make([]InstanceDefinition, 0, len(c.Instances))
+	for _, instance := range c.Instances {
+		instance.Name = name + "-" + instance.Name
+		instances = append(instances, instance)
+	}
+	c.Instances = instances
+
+	accounts := make([]AccountConfig, 0, len(c.Accounts))
+	for _, account := range c.Accounts {
+		copyTo := make([]string, 0, len(account.CopyTo))
+		for _, target := range account.CopyTo {
+			copyTo = append(copyTo, name+"-"+target)
+		}
+		account.CopyTo = copyTo
+		accounts = append(accounts, account)
+	}
+	c.Accounts = accounts
+	return c
+}
+
+// Timeouts holds the time limits of remote operations. A zero value means no
+// limit.
+type Timeouts struct {
+	// SSHDial bounds connecting and authenticating to an instance
+	SSHDial time.Duration
+	// Command bounds a single remote command, including install scripts
+	Command time.Duration
+	// InstancesReady bounds waiting for created instances to become ready
+	InstancesReady time.Duration
+	// ChainStart bounds waiting for the chain to produce its first block
+	ChainStart time.Duration
+}
+
+// GenesisParams holds the parameters the genesis of the network is created
+// with. Zero values keep the celestia-app defaults.
+type GenesisParams struct {
+	// GenesisTime is the genesis time of the chain, the time the genesis is
+	// created if zero
+	GenesisTime time.Time
+	// AppVersion is the app version the chain starts with
+	AppVersion uint64
+
+	// Consensus parameters
+	BlockMaxBytes           int64
+	BlockMaxGas             int64
+	EvidenceMaxAgeNumBlocks int64
+	EvidenceMaxAgeDuration  time.Duration
+	EvidenceMaxBytes        int64
+	ValidatorPubKeyTypes    []string
+
+	// Module parameters
+	UnbondingTime     time.Duration
+	MinCommissionRate string
+	VotingPeriod      time.Duration
+	GovMaxSquareSize  uint64
+
+	// Stake distributes the stake of the genesis validators without a stake
+	// of their own
+	Stake StakeDistribution
+}
+
+// Stake distributions of the genesis validators
+const (
+	// DistributionEqual gives every validator the same stake
+	DistributionEqual = "equal"
+	// DistributionPowerLaw gives the i-th validator a stake proportional to
+	// 1/i^exponent
+	DistributionPowerLaw = "power-law"
+	// DistributionWhale gives the first validator a share of the total stake
+	// and splits the rest equally
+	DistributionWhale = "whale"
+)
+
+// StakeDistribution describes how a total stake is split among validators
+type StakeDistribution struct {
+	// Type is one of the Distribution constants, equal if empty
+	Type string
+	// Total is the stake in utia to split, the default stake per validator
+	// times the number of validators if zero
+	Total int64
+	// Exponent is the exponent of the power-law distribution
+	Exponent float64
+	// WhaleShare is the share of the total stake the whale gets
+	WhaleShare float64
+}
+
+// AccountConfig declares a group of funded genesis accounts
+type AccountConfig struct {
+	// Name is the prefix of the account names, the accounts are named
+	// <name>-<index>
+	Name  string
+	Count int
+	// Balance is the initial balance of every account in utia
+	Balance int64
+	// CopyTo names the instances that receive the keys of the accounts
+	CopyTo []string
 }
 
 // InstanceDefinition defines a single instance with its configuration
 type InstanceDefinition struct {
-	Name                string
-	InstanceConfig      InstanceConfig
-	InstallCelestiaApp  bool
-	InstallCelestiaNode bool
+	Name                string         `json:"name"`
+	Role                NodeType       `json:"role"`
+	InstanceConfig      InstanceConfig `json:"instance_config"`
+	InstallCelestiaApp  bool           `json:"install_celestia_app"`
+	InstallCelestiaNode bool           `json:"install_celestia_node"`
+	// StateSync makes a full node sync from validator snapshots
+	StateSync bool `json:"state_sync"`
+	// Keys are existing keys to deploy the instance with
+	Keys ImportedKeys `json:"keys"`
+	// Stake is the self-delegation of a validator in utia, zero uses the
+	// stake distribution
+	Stake int64 `json:"stake,omitempty"`
+	// Balance is the initial balance of a validator in utia, zero uses the
+	// default balance
+	Balance int64 `json:"balance,omitempty"`
+}
+
+// ImportedKeys points at existing key files an instance is deployed with
+// instead of generated keys. Empty paths keep the generated keys.
+type ImportedKeys struct {
+	// ValidatorKey is a priv_validator_key.json file
+	ValidatorKey string `json:"validator_key,omitempty"`
+	// NodeKey is a node_key.json file
+	NodeKey string `json:"node_key,omitempty"`
+	// Mnemonic is a file holding the mnemonic of the validator account
+	Mnemonic string `json:"mnemonic,omitempty"`
 }
 
 // InstanceConfig holds the configuration for creating instances
 type InstanceConfig struct {
-	Provider     models.ProviderID
-	Region       string
-	Size         string
-	Image        string
-	Tags         []string
-	SSHKeyName   string
-	SSHKeyPath   string
-	VolumeConfig VolumeConfig
+	Provider     models.ProviderID `json:"provider"`
+	Region       string            `json:"region"`
+	Size         string            `json:"size"`
+	Image        string            `json:"image"`
+	Tags         []string          `json:"tags"`
+	SSHKeyName   string            `json:"ssh_key_name"`
+	SSHKeyPath   string            `json:"ssh_key_path"`
+	VolumeConfig VolumeConfig      `json:"volume_config"`
 }
 
 // VolumeConfig holds the configuration for instance volumes
 type VolumeConfig struct {
-	Name       string
-	SizeGB     int
-	MountPoint string
+	Name       string `json:"name"`
+	SizeGB     int    `json:"size_gb"`
+	MountPoint string `json:"mount_point"`
 }
 
 // NewInstanceDefinition creates a new instance definition with default values
@@ -90,6 +249,31 @@ func NewInstanceDefinition(name string, installApp, installNode bool) InstanceDe
 	}
 }
 
+// WithRole sets the node type the instance is deployed as
+func (i InstanceDefinition) WithRole(role NodeType) InstanceDefinition {
+	i.Role = role
+	return i
+}
+
+// WithStateSync enables state sync for the instance
+func (i InstanceDefinition) WithStateSync(enabled bool) InstanceDefinition {
+	i.StateSync = enabled
+	return i
+}
+
+// WithKeys sets the existing keys the instance is deployed with
+func (i InstanceDefinition) WithKeys(keys ImportedKeys) InstanceDefinition {
+	i.Keys = keys
+	return i
+}
+
+// WithTokens sets the stake and the initial balance of a validator
+func (i InstanceDefinition) WithTokens(stake, balance int64) InstanceDefinition {
+	i.Stake = stake
+	i.Balance = balance
+	return i
+}
+
 // WithRegion sets the region for the instance
 func (i InstanceDefinition) WithRegion(region string) InstanceDefinition {
 	i.InstanceConfig.Region = region
@@ -108,6 +292,13 @@ func (i InstanceDefinition) WithVolumeSize(sizeGB int) InstanceDefinition {
 	return i
 }
 
+// WithSSHKey sets the SSH key name and path for the instance
+func (i InstanceDefinition) WithSSHKey(name, path string) InstanceDefinition {
+	i.InstanceConfig.SSHKeyName = name
+	i.InstanceConfig.SSHKeyPath = ExpandPath(path)
+	return i
+}
+
 // WithProvider sets the provider for the instance
 func (i InstanceDefinition) WithProvider(provider string) InstanceDefinition {
 	i.InstanceConfig.Provider = ProviderFromString(provider)
@@ -122,20 +313,33 @@ func DefaultConfig() Config {
 		Username:            "smuu",
 		ProjectName:         "smuu",
 		ProjectDescription:  "smuus project",
+		ChainID:             "test-chain",
+		Environment:         DefaultEnvironment,
 		SSHUsername:         "root",
 		SSHPrivateKeyPath:   "~/.ssh/digitalocean",
 		GoVersion:           "1.23.0",
 		CelestiaAppVersion:  "v3.4.2",
 		CelestiaNodeVersion: "v0.21.9",
+		Timeouts: Timeouts{
+			SSHDial:        30 * time.Second,
+			Command:        30 * time.Minute,
+			InstancesReady: 15 * time.Minute,
+			ChainStart:     5 * time.Minute,
+		},
+		Genesis: GenesisParams{
+			// Larger blocks are rejected by celestia-appd when it reads the
+			// genesis file
+			BlockMaxBytes: 104857600,
+		},
 		Instances: []InstanceDefinition{
 			NewInstanceDefinition("default", true, false),
 		},
 	}
 
 	// Expand paths
-	cfg.SSHPrivateKeyPath = expandPath(cfg.SSHPrivateKeyPath)
+	cfg.SSHPrivateKeyPath = ExpandPath(cfg.SSHPrivateKeyPath)
 	for i := range cfg.Instances {
-		cfg.Instances[i].InstanceConfig.SSHKeyPath = expandPath(cfg.Instances[i].InstanceConfig.SSHKeyPath)
+		cfg.Instances[i].InstanceConfig.SSHKeyPath = ExpandPath(cfg.Instances[i].InstanceConfig.SSHKeyPath)
 	}
 
 	return cfg
@@ -0,0 +1,502 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// NodeType represents the type of Celestia node to deploy
+type NodeType string
+
+const (
+	ValidatorNode NodeType = "validator"
+	BridgeNode    NodeType = "bridge"
+	LightNode     NodeType = "light"
+	FullNode      NodeType = "full"
+)
+
+// Valid reports whether the node type is one of the known node types
+func (t NodeType) Valid() bool {
+	switch t {
+	case ValidatorNode, BridgeNode, LightNode, FullNode:
+		return true
+	}
+	return false
+}
+
+// NodeConfig holds the configuration for a specific node
+type NodeConfig struct {
+	Type       NodeType `yaml:"type"`
+	Count      int      `yaml:"count"`
+	Region     string   `yaml:"region"`
+	Size       string   `yaml:"size"`
+	VolumeSize int      `yaml:"volume_size"`
+	// StateSync lets full nodes sync from validator snapshots
+	StateSync bool `yaml:"state_sync"`
+	// Keys lists existing keys for the first instances of the entry, in order
+	Keys []NodeKeysManifest `yaml:"keys"`
+	// Stake and Balance set the tokens of every validator of the entry in utia
+	Stake   int64 `yaml:"stake"`
+	Balance int64 `yaml:"balance"`
+}
+
+// NodeKeysManifest points at the existing keys of a single instance. Relative
+// paths are resolved against the directory of the manifest.
+type NodeKeysManifest struct {
+	PrivValidatorKey string `yaml:"priv_validator_key"`
+	NodeKey          string `yaml:"node_key"`
+	MnemonicFile     string `yaml:"mnemonic_file"`
+}
+
+// Manifest is the declarative description of a deployment. Every field except
+// the node list is optional and falls back to DefaultConfig when omitted.
+type Manifest struct {
+	ChainID  string                  `yaml:"chain_id"`
+	Talis    TalisManifest           `yaml:"talis"`
+	SSH      SSHManifest             `yaml:"ssh"`
+	Versions VersionsManifest        `yaml:"versions"`
+	Timeouts TimeoutsManifest        `yaml:"timeouts"`
+	Genesis  GenesisManifest         `yaml:"genesis"`
+	Accounts []AccountConfigManifest `yaml:"accounts"`
+	Keys     KeysManifest            `yaml:"keys"`
+	Nodes    []NodeConfig            `yaml:"nodes"`
+
+	// path is the file the manifest was loaded from
+	path string
+	// lines maps dotted field paths (e.g. "nodes[1].count") to their line
+	lines map[string]int
+}
+
+// TalisManifest holds the Talis API and project settings of a manifest
+type TalisManifest struct {
+	BaseURL            string `yaml:"base_url"`
+	Username           string `yaml:"username"`
+	ProjectName        string `yaml:"project_name"`
+	ProjectDescription string `yaml:"project_description"`
+}
+
+// SSHManifest holds the SSH settings of a manifest
+type SSHManifest struct {
+	Username       string `yaml:"username"`
+	PrivateKeyPath string `yaml:"private_key_path"`
+	KeyName        string `yaml:"key_name"`
+}
+
+// VersionsManifest holds the software versions installed on the instances
+type VersionsManifest struct {
+	Go           string `yaml:"go"`
+	CelestiaApp  string `yaml:"celestia_app"`
+	CelestiaNode string `yaml:"celestia_node"`
+}
+
+// TimeoutsManifest holds the time limits of remote operations as Go duration
+// strings, e.g. "30s" or "15m"
+type TimeoutsManifest struct {
+	SSHDial        time.Duration `yaml:"ssh_dial"`
+	Command        time.Duration `yaml:"command"`
+	InstancesReady time.Duration `yaml:"instances_ready"`
+	ChainStart     time.Duration `yaml:"chain_start"`
+}
+
+// GenesisManifest holds the genesis parameters of a manifest
+type GenesisManifest struct {
+	// GenesisTime is an RFC 3339 timestamp, e.g. "2025-01-01T00:00:00Z"
+	GenesisTime time.Time         `yaml:"genesis_time"`
+	AppVersion  uint64            `yaml:"app_version"`
+	Consensus   ConsensusManifest `yaml:"consensus"`
+	Staking     StakingManifest   `yaml:"staking"`
+	Gov         GovManifest       `yaml:"gov"`
+	Blob        BlobManifest      `yaml:"blob"`
+	Stake       StakeManifest     `yaml:"stake"`
+}
+
+// StakeManifest distributes the stake of the genesis validators that do not
+// set a stake of their own
+type StakeManifest struct {
+	// Distribution is equal, power-law or whale
+	Distribution string  `yaml:"distribution"`
+	Total        int64   `yaml:"total"`
+	Exponent     float64 `yaml:"exponent"`
+	WhaleShare   float64 `yaml:"whale_share"`
+}
+
+// ConsensusManifest holds the consensus parameters of the genesis
+type ConsensusManifest struct {
+	BlockMaxBytes           int64         `yaml:"block_max_bytes"`
+	BlockMaxGas             int64         `yaml:"block_max_gas"`
+	EvidenceMaxAgeNumBlocks int64         `yaml:"evidence_max_age_num_blocks"`
+	EvidenceMaxAgeDuration  time.Duration `yaml:"evidence_max_age_duration"`
+	EvidenceMaxBytes        int64         `yaml:"evidence_max_bytes"`
+	ValidatorPubKeyTypes    []string      `yaml:"validator_pub_key_types"`
+}
+
+// StakingManifest holds the staking module parameters of the genesis
+type StakingManifest struct {
+	UnbondingTime time.Duration `yaml:"unbonding_time"`
+	// MinCommissionRate is a decimal between 0 and 1, e.g. "0.05"
+	MinCommissionRate string `yaml:"min_commission_rate"`
+}
+
+// GovManifest holds the gov module parameters of the genesis
+type GovManifest struct {
+	VotingPeriod time.Duration `yaml:"voting_period"`
+}
+
+// BlobManifest holds the blob module parameters of the genesis
+type BlobManifest struct {
+	GovMaxSquareSize uint64 `yaml:"gov_max_square_size"`
+}
+
+// KeysManifest holds the node key settings of the manifest
+type KeysManifest struct {
+	// Seed derives the node keys from the seed and the instance names instead
+	// of generating random keys. Any value, zero included, sets a seed.
+	Seed *int64 `yaml:"seed"`
+}
+
+// AccountConfigManifest declares a group of funded genesis accounts
+type AccountConfigManifest struct {
+	Name    string `yaml:"name"`
+	Count   int    `yaml:"count"`
+	Balance int64  `yaml:"balance"`
+	// CopyTo names the instances, e.g. validator-1, that receive the keys
+	CopyTo []string `yaml:"copy_to"`
+}
+
+// accountNamePattern restricts account name prefixes to what is valid as a
+// keyring key name on the command line
+var accountNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// validatorPubKeyTypes are the consensus key types a chain can accept
+var validatorPubKeyTypes = []string{"ed25519", "secp256k1"}
+
+// stakeDistributions are the supported stake distributions
+var stakeDistributions = []string{DistributionEqual, DistributionPowerLaw, DistributionWhale}
+
+// ManifestError describes a single problem found in a manifest file
+type ManifestError struct {
+	Line  int
+	Field string
+	Msg   string
+}
+
+// ManifestErrors collects all problems found while loading a manifest file
+type ManifestErrors struct {
+	Path   string
+	Errors []ManifestError
+}
+
+// Error implements the error interface
+func (e *ManifestErrors) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		var b strings.Builder
+		b.WriteString(e.Path)
+		if err.Line > 0 {
+			b.WriteString(":" + strconv.Itoa(err.Line))
+		}
+		b.WriteString(": ")
+		if err.Field != "" {
+			b.WriteString(err.Field + ": ")
+		}
+		b.WriteString(err.Msg)
+		msgs = append(msgs, b.String())
+	}
+	return "invalid manifest:\n  " + strings.Join(msgs, "\n  ")
+}
+
+// yamlLineRegexp extracts the line number from yaml.v3 error messages
+var yamlLineRegexp = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+// LoadManifest reads, decodes and validates the manifest file at path
+func LoadManifest(path string) (Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("failed to read manifest %s: %w", path, err)
+	}
+	return ParseManifest(path, data)
+}
+
+// ParseManifest decodes and validates manifest data. The path is only used
+// to prefix error messages.
+func ParseManifest(path string, data []byte) (Manifest, error) {
+	manifest := Manifest{path: path, lines: make(map[string]int)}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return Manifest{}, &ManifestErrors{Path: path, Errors: yamlErrors(err)}
+	}
+	if len(root.Content) == 0 {
+		return Manifest{}, &ManifestErrors{Path: path, Errors: []ManifestError{{Msg: "manifest is empty"}}}
+	}
+	recordLines(root.Content[0], "", manifest.lines)
+
+	// Decode strictly so that typos in field names are reported
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&manifest); err != nil {
+		return Manifest{}, &ManifestErrors{Path: path, Errors: yamlErrors(err)}
+	}
+
+	if errs := manifest.validate(); len(errs) > 0 {
+		return Manifest{}, &ManifestErrors{Path: path, Errors: errs}
+	}
+
+	return manifest, nil
+}
+
+// Path returns the file the manifest was loaded from
+func (m Manifest) Path() string {
+	return m.path
+}
+
+// ResolvePath expands ~ and environment variables in a path from the manifest
+// and makes a relative path relative to the directory of the manifest. An
+// empty path stays empty.
+func (m Manifest) ResolvePath(path string) string {
+	if path == "" {
+		return ""
+	}
+	path = ExpandPath(path)
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(filepath.Dir(m.path), path)
+}
+
+// validate checks the decoded manifest against the schema constraints that
+// cannot be expressed through the YAML types alone
+func (m Manifest) validate() []ManifestError {
+	var errs []ManifestError
+	add := func(field, format string, args ...interface{}) {
+		errs = append(errs, ManifestError{Line: m.line(field), Field: field, Msg: fmt.Sprintf(format, args...)})
+	}
+
+	if m.ChainID != "" && strings.ContainsAny(m.ChainID, " \t/") {
+		add("chain_id", "must not contain whitespace or slashes")
+	}
+	if m.Talis.BaseURL != "" && !strings.HasPrefix(m.Talis.BaseURL, "http://") && !strings.HasPrefix(m.Talis.BaseURL, "https://") {
+		add("talis.base_url", "must be an http or https URL")
+	}
+
+	timeouts := []struct {
+		field   string
+		timeout time.Duration
+	}{
+		{"timeouts.ssh_dial", m.Timeouts.SSHDial},
+		{"timeouts.command", m.Timeouts.Command},
+		{"timeouts.instances_ready", m.Timeouts.InstancesReady},
+		{"timeouts.chain_start", m.Timeouts.ChainStart},
+	}
+	for _, t := range timeouts {
+		if t.timeout < 0 {
+			add(t.field, "must not be negative")
+		}
+	}
+
+	genesisDurations := []struct {
+		field    string
+		duration time.Duration
+	}{
+		{"genesis.consensus.evidence_max_age_duration", m.Genesis.Consensus.EvidenceMaxAgeDuration},
+		{"genesis.staking.unbonding_time", m.Genesis.Staking.UnbondingTime},
+		{"genesis.gov.voting_period", m.Genesis.Gov.VotingPeriod},
+	}
+	for _, d := range genesisDurations {
+		if d.duration < 0 {
+			add(d.field, "must not be negative")
+		}
+	}
+	if m.Genesis.Consensus.BlockMaxBytes < 0 {
+		add("genesis.consensus.block_max_bytes", "must not be negative")
+	}
+	if m.Genesis.Consensus.BlockMaxGas < -1 {
+		add("genesis.consensus.block_max_gas", "must be -1 (unlimited) or more")
+	}
+	if m.Genesis.Consensus.EvidenceMaxAgeNumBlocks < 0 {
+		add("genesis.consensus.evidence_max_age_num_blocks", "must not be negative")
+	}
+	if m.Genesis.Consensus.EvidenceMaxBytes < 0 {
+		add("genesis.consensus.evidence_max_bytes", "must not be negative")
+	}
+	for i, keyType := range m.Genesis.Consensus.ValidatorPubKeyTypes {
+		if !slices.Contains(validatorPubKeyTypes, keyType) {
+			add(fmt.Sprintf("genesis.consensus.validator_pub_key_types[%d]", i), "unknown key type %q (expected one of %s)", keyType, strings.Join(validatorPubKeyTypes, ", "))
+		}
+	}
+	if rate := m.Genesis.Staking.MinCommissionRate; rate != "" {
+		if value, err := strconv.ParseFloat(rate, 64); err != nil || value < 0 || value > 1 {
+			add("genesis.staking.min_commission_rate", "must be a decimal between 0 and 1")
+		}
+	}
+
+	stake := m.Genesis.Stake
+	if stake.Distribution != "" && !slices.Contains(stakeDistributions, stake.Distribution) {
+		add("genesis.stake.distribution", "unknown distribution %q (expected one of %s)", stake.Distribution, strings.Join(stakeDistributions, ", "))
+	}
+	if stake.Total < 0 {
+		add("genesis.stake.total", "must not be negative")
+	}
+	if stake.Exponent != 0 && stake.Distribution != DistributionPowerLaw {
+		add("genesis.stake.exponent", "is only supported for the power-law distribution")
+	}
+	if stake.Exponent < 0 {
+		add("genesis.stake.exponent", "must not be negative")
+	}
+	if stake.WhaleShare != 0 && stake.Distribution != DistributionWhale {
+		add("genesis.stake.whale_share", "is only supported for the whale distribution")
+	}
+	if stake.WhaleShare < 0 || stake.WhaleShare > 1 {
+		add("genesis.stake.whale_share", "must be between 0 and 1")
+	}
+
+	accountNames := make(map[string]bool)
+	for i, account := range m.Accounts {
+		prefix := fmt.Sprintf("accounts[%d]", i)
+		switch {
+		case account.Name == "":
+			add(prefix+".name", "is required")
+		case !accountNamePattern.MatchString(account.Name):
+			add(prefix+".name", "use up to 32 lowercase letters, digits, dashes and underscores")
+		case accountNames[account.Name]:
+			add(prefix+".name", "duplicate account name %q", account.Name)
+		}
+		accountNames[account.Name] = true
+		if account.Count < 1 {
+			add(prefix+".count", "must be at least 1")
+		}
+		if account.Balance <= 0 {
+			add(prefix+".balance", "must be positive")
+		}
+		for j, target := range account.CopyTo {
+			if target == "" {
+				add(fmt.Sprintf("%s.copy_to[%d]", prefix, j), "must not be empty")
+			}
+		}
+	}
+
+	if len(m.Nodes) == 0 {
+		add("nodes", "at least one node entry is required")
+	}
+	for i, node := range m.Nodes {
+		prefix := fmt.Sprintf("nodes[%d]", i)
+		switch {
+		case node.Type == "":
+			add(prefix+".type", "is required")
+		case !node.Type.Valid():
+			add(prefix+".type", "unknown node type %q (expected one of validator, bridge, light, full)", node.Type)
+		}
+		if node.Count < 1 {
+			add(prefix+".count", "must be at least 1")
+		}
+		if node.VolumeSize < 0 {
+			add(prefix+".volume_size", "must not be negative")
+		}
+		if node.StateSync && node.Type != FullNode {
+			add(prefix+".state_sync", "is only supported for full nodes")
+		}
+		if (node.Stake != 0 || node.Balance != 0) && node.Type != ValidatorNode {
+			add(prefix+".stake", "stake and balance are only supported for validators")
+		}
+		if node.Stake < 0 {
+			add(prefix+".stake", "must not be negative")
+		}
+		if node.Balance < 0 {
+			add(prefix+".balance", "must not be negative")
+		}
+		if node.Stake > 0 && node.Balance > 0 && node.Stake > node.Balance {
+			add(prefix+".stake", "must not exceed the balance")
+		}
+		if len(node.Keys) > 0 && len(node.Keys) > node.Count {
+			add(prefix+".keys", "has %d entries for %d nodes", len(node.Keys), node.Count)
+		}
+		for j, keys := range node.Keys {
+			keysPrefix := fmt.Sprintf("%s.keys[%d]", prefix, j)
+			if keys.PrivValidatorKey == "" && keys.NodeKey == "" && keys.MnemonicFile == "" {
+				add(keysPrefix, "must set priv_validator_key, node_key or mnemonic_file")
+			}
+			if keys.PrivValidatorKey != "" && node.Type != ValidatorNode {
+				add(keysPrefix+".priv_validator_key", "is only supported for validators")
+			}
+			if keys.MnemonicFile != "" && node.Type != ValidatorNode {
+				add(keysPrefix+".mnemonic_file", "is only supported for validators")
+			}
+			if keys.NodeKey != "" && node.Type != ValidatorNode && node.Type != FullNode {
+				add(keysPrefix+".node_key", "is only supported for validators and full nodes")
+			}
+		}
+	}
+
+	return errs
+}
+
+// line returns the line of the given field, falling back to its closest
+// parent when the field itself is not present in the file
+func (m Manifest) line(field string) int {
+	for field != "" {
+		if line, ok := m.lines[field]; ok {
+			return line
+		}
+		idx := strings.LastIndexAny(field, ".[")
+		if idx < 0 {
+			break
+		}
+		field = field[:idx]
+	}
+	return 0
+}
+
+// recordLines walks a YAML node tree and records the line of every key
+func recordLines(node *yaml.Node, prefix string, lines map[string]int) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			if prefix != "" {
+				key = prefix + "." + key
+			}
+			lines[key] = node.Content[i].Line
+			recordLines(node.Content[i+1], key, lines)
+		}
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			key := fmt.Sprintf("%s[%d]", prefix, i)
+			lines[key] = item.Line
+			recordLines(item, key, lines)
+		}
+	}
+}
+
+// yamlErrors converts yaml.v3 errors into line-numbered manifest errors
+func yamlErrors(err error) []ManifestError {
+	var msgs []string
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		msgs = typeErr.Errors
+	} else {
+		msgs = []string{err.Error()}
+	}
+
+	errs := make([]ManifestError, 0, len(msgs))
+	for _, msg := range msgs {
+		if match := yamlLineRegexp.FindStringSubmatch(msg); match != nil {
+			line, _ := strconv.Atoi(match[1])
+			errs = append(errs, ManifestError{Line: line, Msg: match[2]})
+			continue
+		}
+		errs = append(errs, ManifestError{Msg: strings.TrimPrefix(msg, "yaml: ")})
+	}
+	sort.SliceStable(errs, func(i, j int) bool { return errs[i].Line < errs[j].Line })
+	return errs
+}
@@ -0,0 +1,235 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseManifest(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		// want lists the expected errors in order; Msg only has to be
+		// contained in the reported message
+		want []ManifestError
+	}{
+		{
+			name: "valid",
+			manifest: `chain_id: test
+genesis:
+  stake:
+    distribution: power-law
+    exponent: 1.5
+nodes:
+  - type: validator
+    count: 2
+    stake: 2000000
+  - type: full
+    count: 1
+    state_sync: true
+`,
+		},
+		{
+			name: "unknown key",
+			manifest: `chain_id: test
+nodes:
+  - type: validator
+    count: 1
+    regoin: nyc1
+`,
+			want: []ManifestError{{Line: 5, Msg: "field regoin not found"}},
+		},
+		{
+			name: "missing nodes",
+			manifest: `chain_id: test
+`,
+			want: []ManifestError{{Line: 0, Field: "nodes", Msg: "at least one node entry is required"}},
+		},
+		{
+			name: "bad count",
+			manifest: `nodes:
+  - type: validator
+    count: 1
+  - type: bridge
+    count: 0
+`,
+			want: []ManifestError{{Line: 5, Field: "nodes[1].count", Msg: "must be at least 1"}},
+		},
+		{
+			name: "missing count",
+			manifest: `nodes:
+  - type: validator
+`,
+			want: []ManifestError{{Line: 2, Field: "nodes[0].count", Msg: "must be at least 1"}},
+		},
+		{
+			name: "unknown role",
+			manifest: `nodes:
+  - type: archive
+    count: 1
+`,
+			want: []ManifestError{{Line: 2, Field: "nodes[0].type", Msg: `unknown node type "archive"`}},
+		},
+		{
+			name: "state sync on validator",
+			manifest: `nodes:
+  - type: validator
+    count: 1
+    state_sync: true
+`,
+			want: []ManifestError{{Line: 4, Field: "nodes[0].state_sync", Msg: "only supported for full nodes"}},
+		},
+		{
+			name: "stake on bridge",
+			manifest: `nodes:
+  - type: bridge
+    count: 1
+    stake: 1000000
+`,
+			want: []ManifestError{{Line: 4, Field: "nodes[0].stake", Msg: "only supported for validators"}},
+		},
+		{
+			name: "stake above balance",
+			manifest: `nodes:
+  - type: validator
+    count: 1
+    stake: 2000000
+    balance: 1000000
+`,
+			want: []ManifestError{{Line: 4, Field: "nodes[0].stake", Msg: "must not exceed the balance"}},
+		},
+		{
+			name: "unknown stake distribution",
+			manifest: `genesis:
+  stake:
+    distribution: pareto
+nodes:
+  - type: validator
+    count: 1
+`,
+			want: []ManifestError{{Line: 3, Field: "genesis.stake.distribution", Msg: `unknown distribution "pareto"`}},
+		},
+		{
+			name: "exponent without power law",
+			manifest: `genesis:
+  stake:
+    distribution: whale
+    exponent: 2
+nodes:
+  - type: validator
+    count: 1
+`,
+			want: []ManifestError{{Line: 4, Field: "genesis.stake.exponent", Msg: "only supported for the power-law distribution"}},
+		},
+		{
+			name: "whale share out of range",
+			manifest: `genesis:
+  stake:
+    distribution: whale
+    whale_share: 1.5
+nodes:
+  - type: validator
+    count: 1
+`,
+			want: []ManifestError{{Line: 4, Field: "genesis.stake.whale_share", Msg: "must be between 0 and 1"}},
+		},
+		{
+			name: "negative total stake",
+			manifest: `genesis:
+  stake:
+    total: -1
+nodes:
+  - type: validator
+    count: 1
+`,
+			want: []ManifestError{{Line: 3, Field: "genesis.stake.total", Msg: "must not be negative"}},
+		},
+		{
+			name: "several errors",
+			manifest: `talis:
+  base_url: ftp://example.com
+nodes:
+  - type: light
+    count: -1
+`,
+			want: []ManifestError{
+				{Line: 2, Field: "talis.base_url", Msg: "must be an http or https URL"},
+				{Line: 5, Field: "nodes[0].count", Msg: "must be at least 1"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manifest, err := ParseManifest("deployment.yaml", []byte(tt.manifest))
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if manifest.Path() != "deployment.yaml" {
+					t.Errorf("path = %q, want deployment.yaml", manifest.Path())
+				}
+				return
+			}
+
+			var manifestErrs *ManifestErrors
+			if !errors.As(err, &manifestErrs) {
+				t.Fatalf("error = %v, want ManifestErrors", err)
+			}
+			if len(manifestErrs.Errors) != len(tt.want) {
+				t.Fatalf("got %d errors, want %d:\n%v", len(manifestErrs.Errors), len(tt.want), err)
+			}
+			for i, want := range tt.want {
+				got := manifestErrs.Errors[i]
+				if got.Line != want.Line || got.Field != want.Field || !strings.Contains(got.Msg, want.Msg) {
+					t.Errorf("error %d = %+v, want %+v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseManifestErrorFormat(t *testing.T) {
+	_, err := ParseManifest("deployment.yaml", []byte(`nodes:
+  - type: validator
+    count: 0
+`))
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	want := "deployment.yaml:3: nodes[0].count: must be at least 1"
+	if !strings.Contains(err.Error(), want) {
+		t.Errorf("error = %q, want it to contain %q", err.Error(), want)
+	}
+}
+
+func TestParseManifestEmpty(t *testing.T) {
+	_, err := ParseManifest("deployment.yaml", nil)
+	var manifestErrs *ManifestErrors
+	if !errors.As(err, &manifestErrs) || len(manifestErrs.Errors) != 1 || manifestErrs.Errors[0].Msg != "manifest is empty" {
+		t.Errorf("error = %v, want manifest is empty", err)
+	}
+}
+
+func TestParseManifestKeySeed(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		manifest string
+		want     *int64
+	}{
+		{name: "unset", manifest: "nodes:\n  - type: validator\n    count: 1\n"},
+		{name: "zero", manifest: "keys:\n  seed: 0\nnodes:\n  - type: validator\n    count: 1\n", want: new(int64)},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			manifest, err := ParseManifest("deployment.yaml", []byte(tt.manifest))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got := manifest.Keys.Seed
+			if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+				t.Errorf("seed = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
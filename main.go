@@ -2,35 +2,18 @@ package main
 
 import (
 	"context"
-	"flag"
 	"fmt"
 	"log"
-	"time"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/celestiaorg/talis-test/config"
 	"github.com/celestiaorg/talis-test/manager"
 	"github.com/joho/godotenv"
+	"github.com/spf13/cobra"
 )
 
-// NodeType represents the type of Celestia node to deploy
-type NodeType string
-
-const (
-	ValidatorNode NodeType = "validator"
-	BridgeNode    NodeType = "bridge"
-	LightNode     NodeType = "light"
-	FullNode      NodeType = "full"
-)
-
-// NodeConfig holds the configuration for a specific node
-type NodeConfig struct {
-	Type       NodeType
-	Count      int
-	Region     string
-	Size       string
-	VolumeSize int
-}
-
 func main() {
 	// Load environment variables from .env file
 	err := godotenv.Load()
@@ -38,154 +21,271 @@ func main() {
 		log.Printf("Warning: Error loading .env file: %v", err)
 	}
 
-	// Parse command line flags
-	infraFlag := flag.Bool("infra", false, "Create infrastructure (servers with Talis)")
-	prepareToolsFlag := flag.Bool("prepare-tools", false, "Install required tools (Go, Celestia)")
-	prepareChainFlag := flag.Bool("prepare-chain", false, "Create and add chain files")
-	startFlag := flag.Bool("start", false, "Start the validators")
-	deleteFlag := flag.Bool("delete", false, "Delete all deployed instances")
-	chainIDFlag := flag.String("chain-id", "test-chain", "Chain ID for the Celestia network")
-	flag.Parse()
-
-	// Define your deployment configuration here
-	deployment := struct {
-		Nodes []NodeConfig
-	}{
-		Nodes: []NodeConfig{
-			{
-				Type:       ValidatorNode,
-				Count:      4,
-				Region:     "nyc1",
-				Size:       "s-2vcpu-4gb",
-				VolumeSize: 30,
-			},
-		},
-	}
-
-	// Get configuration based on deployment specification
-	cfg := getConfiguration(deployment.Nodes)
-
-	// Create manager
-	mgr, err := manager.NewTalisManager(cfg)
+	// The first interrupt cancels the running operation so that it can stop
+	// cleanly and record its progress, a second one exits immediately
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	err = newRootCmd().ExecuteContext(ctx)
+	stop()
 	if err != nil {
-		log.Fatalf("Failed to create manager: %v", err)
+		os.Exit(1)
 	}
+}
 
-	ctx := context.Background()
+// rootOptions holds the flags shared by all subcommands
+type rootOptions struct {
+	manifestPath string
+	environment  string
+}
 
-	// If delete flag is set, only delete instances and exit
-	if *deleteFlag {
-		log.Println("Deleting all instances...")
-		if err := mgr.DeleteAllInstances(ctx); err != nil {
-			log.Fatalf("Failed to delete instances: %v", err)
-		}
-		log.Println("Instance deletion completed successfully")
-		return
+// newRootCmd creates the root command with all subcommands attached
+func newRootCmd() *cobra.Command {
+	opts := &rootOptions{}
+
+	cmd := &cobra.Command{
+		Use:          "talis-test",
+		Short:        "Deploy and operate Celestia test networks on Talis",
+		SilenceUsage: true,
 	}
+	cmd.PersistentFlags().StringVarP(&opts.manifestPath, "manifest", "m", "deployment.yaml", "Path to the deployment manifest")
+	cmd.PersistentFlags().StringVarP(&opts.environment, "env", "e", "", "Environment to operate on (defaults to the one selected with env use)")
 
-	// Run infrastructure setup if requested
-	if *infraFlag {
-		log.Println("Preparing infrastructure...")
-		if err := mgr.PrepareInfrastructure(ctx); err != nil {
-			log.Fatalf("Failed to prepare infrastructure: %v", err)
-		}
-		log.Println("Infrastructure preparation completed successfully")
+	cmd.AddCommand(
+		newUpCmd(opts),
+		newInfraCmd(opts),
+		newInstallCmd(opts),
+		newGenesisCmd(opts),
+		newStartCmd(opts),
+		newScaleOutCmd(opts),
+		newStopCmd(opts),
+		newStatusCmd(opts),
+		newPlanCmd(opts),
+		newApplyCmd(opts),
+		newDestroyCmd(opts),
+		newSSHCmd(opts),
+		newTrustCmd(opts),
+		newEnvCmd(opts),
+	)
+
+	return cmd
+}
+
+// selectedEnvironment returns the environment named by the --env flag, or the
+// one selected with env use
+func (o *rootOptions) selectedEnvironment() (string, error) {
+	if o.environment != "" {
+		return o.environment, nil
 	}
+	return manager.CurrentEnvironment()
+}
 
-	// Run tools installation if requested
-	if *prepareToolsFlag {
-		log.Println("Installing required tools...")
+// loadConfig loads the manifest and turns it into a configuration scoped to
+// the given environment
+func (o *rootOptions) loadConfig(env string) (config.Config, error) {
+	if err := config.ValidateEnvironmentName(env); err != nil {
+		return config.Config{}, err
+	}
 
-		// Install Go
-		log.Println("Installing Go on instances...")
-		if err := mgr.InstallGoOnInstances(ctx); err != nil {
-			log.Fatalf("Failed to install Go on instances: %v", err)
-		}
-		log.Println("Go installation completed successfully")
+	manifest, err := config.LoadManifest(o.manifestPath)
+	if err != nil {
+		return config.Config{}, fmt.Errorf("failed to load manifest: %w", err)
+	}
+	return getConfiguration(manifest).WithEnvironment(env), nil
+}
 
-		// Install Celestia App
-		log.Println("Installing Celestia App on configured instances...")
-		if err := mgr.InstallCelestiaAppOnInstances(ctx); err != nil {
-			log.Fatalf("Failed to install Celestia App on instances: %v", err)
-		}
-		log.Println("Celestia App installation completed successfully")
+// newManager loads the configuration of the selected environment and creates
+// a manager for it
+func (o *rootOptions) newManager() (*manager.TalisManager, config.Config, error) {
+	env, err := o.selectedEnvironment()
+	if err != nil {
+		return nil, config.Config{}, err
+	}
+	return o.newEnvironmentManager(env)
+}
 
-		// Install Celestia Node
-		log.Println("Installing Celestia Node on configured instances...")
-		if err := mgr.InstallCelestiaNodeOnInstances(ctx); err != nil {
-			log.Fatalf("Failed to install Celestia Node on instances: %v", err)
-		}
-		log.Println("Celestia Node installation completed successfully")
+// newEnvironmentManager loads the configuration of the given environment and
+// creates a manager for it
+func (o *rootOptions) newEnvironmentManager(env string) (*manager.TalisManager, config.Config, error) {
+	cfg, err := o.loadConfig(env)
+	if err != nil {
+		return nil, config.Config{}, err
 	}
 
-	// Run chain preparation if requested
-	if *prepareChainFlag {
-		log.Println("Setting up Celestia network...")
-		if err := mgr.SetupCelestiaNetwork(ctx, *chainIDFlag); err != nil {
-			log.Fatalf("Failed to set up Celestia network: %v", err)
-		}
-		log.Println("Celestia network setup completed successfully")
+	mgr, err := manager.NewTalisManager(cfg)
+	if err != nil {
+		return nil, config.Config{}, fmt.Errorf("failed to create manager: %w", err)
 	}
+	return mgr, cfg, nil
+}
 
-	// Run validator start if requested
-	if *startFlag {
-		log.Println("Starting Celestia App service on configured instances...")
-		if err := mgr.SetupCelestiaAppService(ctx); err != nil {
-			log.Fatalf("Failed to start Celestia App service: %v", err)
-		}
-		log.Println("Celestia App service started successfully")
+// newLockedManager creates a manager like newManager and acquires the state
+// lock, which is held until the manager is closed. Every command that modifies
+// the state or the deployment uses it so that concurrent runs cannot interfere.
+func (o *rootOptions) newLockedManager() (*manager.TalisManager, config.Config, error) {
+	env, err := o.selectedEnvironment()
+	if err != nil {
+		return nil, config.Config{}, err
+	}
+	return o.newLockedEnvironmentManager(env)
+}
+
+// newLockedEnvironmentManager creates a manager for the given environment and
+// acquires its state lock
+func (o *rootOptions) newLockedEnvironmentManager(env string) (*manager.TalisManager, config.Config, error) {
+	mgr, cfg, err := o.newEnvironmentManager(env)
+	if err != nil {
+		return nil, config.Config{}, err
 	}
 
-	// If no flags are set, show usage
-	if !*infraFlag && !*prepareToolsFlag && !*prepareChainFlag && !*startFlag && !*deleteFlag {
-		fmt.Println("No action specified. Use one of the following flags:")
-		fmt.Println("  --infra         Create infrastructure (servers with Talis)")
-		fmt.Println("  --prepare-tools Install required tools (Go, Celestia)")
-		fmt.Println("  --prepare-chain Create and add chain files")
-		fmt.Println("  --start         Start the validators")
-		fmt.Println("  --delete        Delete all deployed instances")
-		fmt.Println("\nAdditional options:")
-		fmt.Println("  --chain-id      Chain ID for the Celestia network (default: test-chain)")
+	if err := mgr.LockState(); err != nil {
+		mgr.Close()
+		return nil, config.Config{}, err
 	}
+	return mgr, cfg, nil
 }
 
 // getConfiguration returns the configuration for the application
-// This is where you define your instances and their configurations
-func getConfiguration(nodes []NodeConfig) config.Config {
+// Values set in the manifest override the defaults
+func getConfiguration(manifest config.Manifest) config.Config {
 	// Start with default configuration
 	cfg := config.DefaultConfig()
 
+	if manifest.ChainID != "" {
+		cfg.ChainID = manifest.ChainID
+	}
+	if manifest.Talis.BaseURL != "" {
+		cfg.BaseURL = manifest.Talis.BaseURL
+	}
+	if manifest.Talis.Username != "" {
+		cfg.Username = manifest.Talis.Username
+	}
+	if manifest.Talis.ProjectName != "" {
+		cfg.ProjectName = manifest.Talis.ProjectName
+	}
+	if manifest.Talis.ProjectDescription != "" {
+		cfg.ProjectDescription = manifest.Talis.ProjectDescription
+	}
+	if manifest.SSH.Username != "" {
+		cfg.SSHUsername = manifest.SSH.Username
+	}
+	if manifest.SSH.PrivateKeyPath != "" {
+		cfg.SSHPrivateKeyPath = config.ExpandPath(manifest.SSH.PrivateKeyPath)
+	}
+	if manifest.Versions.Go != "" {
+		cfg.GoVersion = manifest.Versions.Go
+	}
+	if manifest.Versions.CelestiaApp != "" {
+		cfg.CelestiaAppVersion = manifest.Versions.CelestiaApp
+	}
+	if manifest.Versions.CelestiaNode != "" {
+		cfg.CelestiaNodeVersion = manifest.Versions.CelestiaNode
+	}
+
+	if manifest.Timeouts.SSHDial != 0 {
+		cfg.Timeouts.SSHDial = manifest.Timeouts.SSHDial
+	}
+	if manifest.Timeouts.Command != 0 {
+		cfg.Timeouts.Command = manifest.Timeouts.Command
+	}
+	if manifest.Timeouts.InstancesReady != 0 {
+		cfg.Timeouts.InstancesReady = manifest.Timeouts.InstancesReady
+	}
+	if manifest.Timeouts.ChainStart != 0 {
+		cfg.Timeouts.ChainStart = manifest.Timeouts.ChainStart
+	}
+
+	genesis := manifest.Genesis
+	cfg.Genesis.GenesisTime = genesis.GenesisTime
+	cfg.Genesis.AppVersion = genesis.AppVersion
+	if genesis.Consensus.BlockMaxBytes != 0 {
+		cfg.Genesis.BlockMaxBytes = genesis.Consensus.BlockMaxBytes
+	}
+	cfg.Genesis.BlockMaxGas = genesis.Consensus.BlockMaxGas
+	cfg.Genesis.EvidenceMaxAgeNumBlocks = genesis.Consensus.EvidenceMaxAgeNumBlocks
+	cfg.Genesis.EvidenceMaxAgeDuration = genesis.Consensus.EvidenceMaxAgeDuration
+	cfg.Genesis.EvidenceMaxBytes = genesis.Consensus.EvidenceMaxBytes
+	cfg.Genesis.ValidatorPubKeyTypes = genesis.Consensus.ValidatorPubKeyTypes
+	cfg.Genesis.UnbondingTime = genesis.Staking.UnbondingTime
+	cfg.Genesis.MinCommissionRate = genesis.Staking.MinCommissionRate
+	cfg.Genesis.VotingPeriod = genesis.Gov.VotingPeriod
+	cfg.Genesis.GovMaxSquareSize = genesis.Blob.GovMaxSquareSize
+	cfg.Genesis.Stake = config.StakeDistribution{
+		Type:       genesis.Stake.Distribution,
+		Total:      genesis.Stake.Total,
+		Exponent:   genesis.Stake.Exponent,
+		WhaleShare: genesis.Stake.WhaleShare,
+	}
+
+	for _, account := range manifest.Accounts {
+		cfg.Accounts = append(cfg.Accounts, config.AccountConfig{
+			Name:    account.Name,
+			Count:   account.Count,
+			Balance: account.Balance,
+			CopyTo:  account.CopyTo,
+		})
+	}
+
+	cfg.KeySeed = manifest.Keys.Seed
+
 	// Clear default instances
 	cfg.Instances = []config.InstanceDefinition{}
 
-	// Create instances based on node configuration
-	for _, nodeConfig := range nodes {
-		for i := 1; i <= nodeConfig.Count; i++ {
+	// Create instances based on node configuration. Names are numbered per
+	// node type so that they stay the same across invocations and the
+	// instances in state can be matched to their definitions.
+	counts := make(map[config.NodeType]int)
+	for _, nodeConfig := range manifest.Nodes {
+		for i := range nodeConfig.Count {
+			counts[nodeConfig.Type]++
 			// Determine which components to install based on node type
 			installApp := false
 			installNode := false
 
 			switch nodeConfig.Type {
-			case ValidatorNode:
+			case config.ValidatorNode:
 				installApp = true
-			case BridgeNode:
+			case config.BridgeNode:
 				installNode = true
-			case LightNode:
+			case config.LightNode:
 				installNode = true
-			case FullNode:
+			case config.FullNode:
 				installApp = true
 				installNode = true
 			}
 
 			// Create the instance definition
 			instance := config.NewInstanceDefinition(
-				string(nodeConfig.Type)+"-"+fmt.Sprintf("%d", i)+"-"+fmt.Sprint(time.Now().Unix()),
+				fmt.Sprintf("%s-%d", nodeConfig.Type, counts[nodeConfig.Type]),
 				installApp,
 				installNode,
 			).
-				WithRegion(nodeConfig.Region).
-				WithSize(nodeConfig.Size).
-				WithVolumeSize(nodeConfig.VolumeSize)
+				WithRole(nodeConfig.Type).
+				WithStateSync(nodeConfig.StateSync).
+				WithTokens(nodeConfig.Stake, nodeConfig.Balance)
+			if nodeConfig.Region != "" {
+				instance = instance.WithRegion(nodeConfig.Region)
+			}
+			if nodeConfig.Size != "" {
+				instance = instance.WithSize(nodeConfig.Size)
+			}
+			if nodeConfig.VolumeSize > 0 {
+				instance = instance.WithVolumeSize(nodeConfig.VolumeSize)
+			}
+			if manifest.SSH.KeyName != "" {
+				instance = instance.WithSSHKey(manifest.SSH.KeyName, cfg.SSHPrivateKeyPath)
+			}
+			if i < len(nodeConfig.Keys) {
+				keys := nodeConfig.Keys[i]
+				instance = instance.WithKeys(config.ImportedKeys{
+					ValidatorKey: manifest.ResolvePath(keys.PrivValidatorKey),
+					NodeKey:      manifest.ResolvePath(keys.NodeKey),
+					Mnemonic:     manifest.ResolvePath(keys.MnemonicFile),
+				})
+			}
 
 			// Add instance to configuration
 			cfg.Instances = append(cfg.Instances, instance)
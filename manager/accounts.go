@@ -0,0 +1,200 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/celestiaorg/celestia-app/v3/test/util/genesis"
+	"github.com/cosmos/cosmos-sdk/codec"
+	"github.com/cosmos/cosmos-sdk/crypto/hd"
+	"github.com/cosmos/cosmos-sdk/crypto/keyring"
+	cryptotypes "github.com/cosmos/cosmos-sdk/crypto/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// FundedAccount is a pre-funded genesis account whose key is kept in the
+// local keyring of the environment
+type FundedAccount struct {
+	Name     string `json:"name"`
+	Address  string `json:"address"`
+	Mnemonic string `json:"mnemonic,omitempty"`
+	Balance  int64  `json:"balance"`
+
+	// copyTo names the instances that receive the key
+	copyTo []string
+	pubKey cryptotypes.PubKey
+}
+
+// AccountsDir returns the directory holding the keyring and the accounts file
+// of an environment. The keyring is in the keyring-test subdirectory, so it
+// is used with --keyring-backend test --keyring-dir <dir>.
+func AccountsDir(environment string) (string, error) {
+	dir, err := EnvironmentDir(environment)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "accounts"), nil
+}
+
+// ensureAccounts returns the configured accounts. Their keys are created in
+// the local keyring unless a previous genesis created them already, so the
+// addresses stay the same across networks. The accounts with their mnemonics
+// are written to accounts.json next to the keyring.
+func (m *TalisManager) ensureAccounts(cdc codec.Codec) ([]FundedAccount, keyring.Keyring, error) {
+	for _, group := range m.config.Accounts {
+		for _, target := range group.CopyTo {
+			if _, ok := m.accountTarget(target); !ok {
+				return nil, nil, fmt.Errorf("instance %s to copy the %s accounts to is not configured", target, group.Name)
+			}
+		}
+	}
+
+	dir, err := AccountsDir(m.config.Environment)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, nil, err
+	}
+
+	kr, err := keyring.New("celestia-app", keyring.BackendTest, dir, nil, cdc)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open keyring: %w", err)
+	}
+
+	// Mnemonics are only known when a key is created
+	accountsPath := filepath.Join(dir, "accounts.json")
+	mnemonics := make(map[string]string)
+	data, err := os.ReadFile(accountsPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, nil, fmt.Errorf("failed to read accounts file: %w", err)
+	}
+	if err == nil {
+		var existing []FundedAccount
+		if err := json.Unmarshal(data, &existing); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse accounts file %s: %w", accountsPath, err)
+		}
+		for _, account := range existing {
+			mnemonics[account.Name] = account.Mnemonic
+		}
+	}
+
+	var accounts []FundedAccount
+	for _, group := range m.config.Accounts {
+		for i := range group.Count {
+			name := fmt.Sprintf("%s-%d", group.Name, i)
+
+			record, err := kr.Key(name)
+			mnemonic := mnemonics[name]
+			if err != nil {
+				record, mnemonic, err = kr.NewMnemonic(name, keyring.English, sdk.FullFundraiserPath, keyring.DefaultBIP39Passphrase, hd.Secp256k1)
+				if err != nil {
+					return nil, nil, fmt.Errorf("failed to create key %s: %w", name, err)
+				}
+			}
+
+			address, err := record.GetAddress()
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to get address of key %s: %w", name, err)
+			}
+			pubKey, err := record.GetPubKey()
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to get public key of key %s: %w", name, err)
+			}
+
+			accounts = append(accounts, FundedAccount{
+				Name:     name,
+				Address:  address.String(),
+				Mnemonic: mnemonic,
+				Balance:  group.Balance,
+				copyTo:   group.CopyTo,
+				pubKey:   pubKey,
+			})
+		}
+	}
+
+	data, err = json.MarshalIndent(accounts, "", "  ")
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := writeFileAtomic(accountsPath, data, 0600); err != nil {
+		return nil, nil, fmt.Errorf("failed to write accounts file: %w", err)
+	}
+
+	return accounts, kr, nil
+}
+
+// AddAccounts funds the accounts in the genesis
+func (n *CelestiaNetwork) AddAccounts(accounts []FundedAccount) error {
+	for _, account := range accounts {
+		err := n.genesis.AddAccount(genesis.Account{
+			PubKey:  account.pubKey,
+			Balance: account.Balance,
+			Name:    account.Name,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to add account %s to genesis: %w", account.Name, err)
+		}
+	}
+	return nil
+}
+
+// copyAccounts copies the accounts to the instances they are configured for.
+// Every instance gets the accounts in /root/accounts.json, and instances with
+// Celestia App also get their keys in the test keyring.
+func (m *TalisManager) copyAccounts(ctx context.Context, accounts []FundedAccount, kr keyring.Keyring) error {
+	targets := make(map[string][]FundedAccount)
+	var names []string
+	for _, account := range accounts {
+		for _, target := range account.copyTo {
+			if _, ok := targets[target]; !ok {
+				names = append(names, target)
+			}
+			targets[target] = append(targets[target], account)
+		}
+	}
+
+	for _, target := range names {
+		instance, ok := m.accountTarget(target)
+		if !ok {
+			return fmt.Errorf("instance %s to copy accounts to is not configured", target)
+		}
+		if instance.PublicIP == "" {
+			return fmt.Errorf("instance %s has no public IP", instance.Name)
+		}
+
+		data, err := json.MarshalIndent(targets[target], "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := m.sshManager.WriteToFile(ctx, instance.PublicIP, "/root/accounts.json", string(data), 0600); err != nil {
+			return fmt.Errorf("failed to write accounts to instance %s: %w", instance.Name, err)
+		}
+
+		if instance.Definition.InstallCelestiaApp {
+			for _, account := range targets[target] {
+				if err := importKey(ctx, m.sshManager, instance.PublicIP, "/root/.celestia-app", kr, account.Name); err != nil {
+					return fmt.Errorf("failed to import key %s on instance %s: %w", account.Name, instance.Name, err)
+				}
+			}
+		}
+		log.Printf("Copied %d accounts to instance %s (%s)", len(targets[target]), instance.Name, instance.PublicIP)
+	}
+
+	return nil
+}
+
+// accountTarget returns the instance an account copy target refers to, by
+// the name of its definition or its instance name
+func (m *TalisManager) accountTarget(target string) (InstanceInfo, bool) {
+	for _, instance := range m.state.Instances[m.config.ProjectName] {
+		if instance.Definition.Name == target || instance.Name == target {
+			return instance, true
+		}
+	}
+	return InstanceInfo{}, false
+}
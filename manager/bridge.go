@@ -0,0 +1,118 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/celestiaorg/talis-test/config"
+)
+
+// instancesByRole returns the instances in state that have the given role
+func (m *TalisManager) instancesByRole(role config.NodeType) []InstanceInfo {
+	var instances []InstanceInfo
+	for _, instance := range m.state.Instances[m.config.ProjectName] {
+		if instance.Role != role {
+			continue
+		}
+		instances = append(instances, instance)
+	}
+	return instances
+}
+
+// SetupBridgeNodes initializes the bridge node stores and runs the bridges as
+// systemd services. Each bridge is connected to a validator of the deployment,
+// assigned round-robin, and configured for the private network identified by
+// the chain ID and the hash of its first block.
+func (m *TalisManager) SetupBridgeNodes(ctx context.Context, chainID string) error {
+	// Load state
+	state, err := m.LoadState()
+	if err != nil {
+		return fmt.Errorf("failed to load state: %w", err)
+	}
+	m.state = state
+
+	// Check instances against configuration
+	if err := m.checkInstances(); err != nil {
+		return err
+	}
+
+	bridges := m.instancesByRole(config.BridgeNode)
+	if len(bridges) == 0 {
+		log.Println("No bridge nodes configured")
+		return nil
+	}
+
+	var validators []InstanceInfo
+	for _, validator := range m.instancesByRole(config.ValidatorNode) {
+		if validator.PublicIP != "" {
+			validators = append(validators, validator)
+		}
+	}
+	if len(validators) == 0 {
+		return fmt.Errorf("no validators with a public IP available for %d bridge nodes", len(bridges))
+	}
+
+	// The genesis hash identifies the private network for celestia-node
+	log.Printf("Waiting for the first block on validator %s (%s)...", validators[0].Name, validators[0].PublicIP)
+	genesisHash, err := waitForGenesisHash(ctx, validators[0].PublicIP, m.config.Timeouts.ChainStart)
+	if err != nil {
+		return fmt.Errorf("failed to get genesis hash: %w", err)
+	}
+	log.Printf("Genesis hash of %s: %s", chainID, genesisHash)
+
+	// Create a semaphore to limit concurrent operations
+	sem := make(chan struct{}, 10)
+	errChan := make(chan error, len(bridges))
+	var wg sync.WaitGroup
+
+	for i, bridge := range bridges {
+		if bridge.PublicIP == "" {
+			log.Printf("Skipping instance %d: no public IP", bridge.ID)
+			continue
+		}
+
+		wg.Add(1)
+		go func(inst InstanceInfo, core InstanceInfo) {
+			defer wg.Done()
+
+			// Acquire semaphore
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			log.Printf("Setting up bridge node on instance %s (%s) with core %s (%s)...", inst.Name, inst.PublicIP, core.Name, core.PublicIP)
+
+			// Copy the service setup script to the remote machine
+			if err := m.sshManager.CopyFile(ctx, inst.PublicIP, "scripts/setup_celestia_bridge_service.sh", "setup_celestia_bridge_service.sh"); err != nil {
+				errChan <- fmt.Errorf("failed to copy bridge setup script to instance %s (%s): %w", inst.Name, inst.PublicIP, err)
+				return
+			}
+
+			// Make the script executable and run it
+			cmd := fmt.Sprintf("chmod +x setup_celestia_bridge_service.sh && ./setup_celestia_bridge_service.sh %s %s %s", core.PublicIP, chainID, genesisHash)
+			if err := m.runStreaming(ctx, inst, cmd); err != nil {
+				errChan <- fmt.Errorf("failed to execute bridge setup script on instance %s (%s): %w", inst.Name, inst.PublicIP, err)
+				return
+			}
+
+			log.Printf("Successfully set up bridge node on instance %s (%s)", inst.Name, inst.PublicIP)
+			if err := m.markStage(inst.Name, StageStarted); err != nil {
+				errChan <- err
+			}
+		}(bridge, validators[i%len(validators)])
+	}
+
+	// Wait for all goroutines to complete
+	wg.Wait()
+	close(errChan)
+
+	// Check for any errors
+	for err := range errChan {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
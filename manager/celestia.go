@@ -2,6 +2,9 @@ package manager
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -9,26 +12,44 @@ import (
 
 	"github.com/celestiaorg/celestia-app/v3/app"
 	"github.com/celestiaorg/celestia-app/v3/test/util/genesis"
+	"github.com/cosmos/cosmos-sdk/crypto/hd"
+	"github.com/cosmos/cosmos-sdk/crypto/keyring"
 	serverconfig "github.com/cosmos/cosmos-sdk/server/config"
+	sdk "github.com/cosmos/cosmos-sdk/types"
 	"github.com/tendermint/tendermint/config"
 	"github.com/tendermint/tendermint/p2p"
 	"github.com/tendermint/tendermint/privval"
 )
 
+const (
+	// appdBinary is the path celestia-appd is installed to
+	appdBinary = "/usr/local/bin/celestia-appd"
+	// validatorBalance is the default initial balance of a genesis validator
+	// in utia
+	validatorBalance = int64(1e16)
+	// validatorStake is the default self-delegation of a validator in utia
+	validatorStake = int64(1e12)
+)
+
 // CelestiaNetwork represents a Celestia network configuration
 type CelestiaNetwork struct {
-	chainID    string
-	genesis    *genesis.Genesis
-	keygen     *keyGenerator
-	nodes      []*CelestiaNode
-	sshManager *SSHManager
+	chainID          string
+	genesis          *genesis.Genesis
+	nodes            []*CelestiaNode
+	sshManager       *SSHManager
+	snapshotInterval uint64
+	// genesisJSON is the genesis file, set by SetupNetwork
+	genesisJSON []byte
 }
 
 // CelestiaNode represents a Celestia node in the network
 type CelestiaNode struct {
-	name       string
+	name string
+	// signerKey is only set for validators, full nodes do not sign blocks
 	signerKey  *keyPair
 	networkKey *keyPair
+	// tokens are only set for validators
+	tokens     validatorTokens
 	sshManager *SSHManager
 	homeDir    string
 	publicIP   string
@@ -39,28 +60,48 @@ func NewCelestiaNetwork(chainID string, sshManager *SSHManager) *CelestiaNetwork
 	return &CelestiaNetwork{
 		chainID:    chainID,
 		genesis:    genesis.NewDefaultGenesis().WithChainID(chainID),
-		keygen:     newKeyGenerator(42), // Use a fixed seed for reproducibility
 		sshManager: sshManager,
 		nodes:      make([]*CelestiaNode, 0),
 	}
 }
 
-// CreateGenesisNode creates a new genesis validator node
-func (n *CelestiaNetwork) CreateGenesisNode(ctx context.Context, name, homeDir, publicIP string) error {
-	signerKey := n.keygen.Generate(ed25519Type)
-	networkKey := n.keygen.Generate(ed25519Type)
-
+// CreateGenesisNode creates a new genesis validator node with the given keys
+// and tokens. The account of the validator is recovered from the mnemonic if
+// one is given, otherwise a new account is created.
+func (n *CelestiaNetwork) CreateGenesisNode(ctx context.Context, name, homeDir, publicIP string, signerKey, networkKey *keyPair, mnemonic string, tokens validatorTokens) error {
 	node := &CelestiaNode{
 		name:       name,
 		signerKey:  signerKey,
 		networkKey: networkKey,
+		tokens:     tokens,
 		sshManager: n.sshManager,
 		homeDir:    homeDir,
 		publicIP:   publicIP,
 	}
 
 	// Add validator to genesis
-	if err := n.genesis.NewValidator(node.GenesisValidator()); err != nil {
+	validator := node.GenesisValidator()
+	if mnemonic != "" {
+		record, err := n.genesis.Keyring().NewAccount(name, mnemonic, keyring.DefaultBIP39Passphrase, sdk.FullFundraiserPath, hd.Secp256k1)
+		if err != nil {
+			return fmt.Errorf("failed to recover account from mnemonic: %w", err)
+		}
+		pubKey, err := record.GetPubKey()
+		if err != nil {
+			return err
+		}
+		err = n.genesis.AddAccount(genesis.Account{
+			PubKey:  pubKey,
+			Balance: validator.InitialTokens,
+			Name:    name,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to add account to genesis: %w", err)
+		}
+		if err := n.genesis.AddValidator(validator); err != nil {
+			return fmt.Errorf("failed to add validator to genesis: %w", err)
+		}
+	} else if err := n.genesis.NewValidator(validator); err != nil {
 		return fmt.Errorf("failed to add validator to genesis: %w", err)
 	}
 
@@ -68,6 +109,33 @@ func (n *CelestiaNetwork) CreateGenesisNode(ctx context.Context, name, homeDir,
 	return nil
 }
 
+// CreateFullNode creates a new non-validator consensus node. It receives the
+// genesis and peers of the network but has no validator key or stake.
+func (n *CelestiaNetwork) CreateFullNode(ctx context.Context, name, homeDir, publicIP string, networkKey *keyPair) error {
+	node := &CelestiaNode{
+		name:       name,
+		networkKey: networkKey,
+		sshManager: n.sshManager,
+		homeDir:    homeDir,
+		publicIP:   publicIP,
+	}
+
+	n.nodes = append(n.nodes, node)
+	return nil
+}
+
+// WithSnapshotInterval enables state sync snapshots on the validators every
+// interval blocks so that full nodes can state sync from them
+func (n *CelestiaNetwork) WithSnapshotInterval(interval uint64) *CelestiaNetwork {
+	n.snapshotInterval = interval
+	return n
+}
+
+// IsValidator reports whether the node is a genesis validator
+func (n *CelestiaNode) IsValidator() bool {
+	return n.signerKey != nil
+}
+
 // SetupNetwork sets up the Celestia network on the instances
 func (n *CelestiaNetwork) SetupNetwork(ctx context.Context) error {
 	fmt.Printf("Starting Celestia network setup with %d nodes...\n", len(n.nodes))
@@ -81,17 +149,23 @@ func (n *CelestiaNetwork) SetupNetwork(ctx context.Context) error {
 		fmt.Printf("Node %s setup completed\n", node.name)
 	}
 
-	// Get peer addresses
+	// Get peer addresses, every node peers with the validators
 	fmt.Println("Configuring peer connections...")
 	peers := make([]string, 0, len(n.nodes))
 	for _, node := range n.nodes {
-		peers = append(peers, node.AddressP2P())
+		if node.IsValidator() {
+			peers = append(peers, node.AddressP2P())
+		}
 	}
 
 	// Setup each node with configuration files
 	for _, node := range n.nodes {
 		fmt.Printf("Configuring node %s...\n", node.name)
-		if err := node.setupConfig(peers); err != nil {
+		snapshotInterval := uint64(0)
+		if node.IsValidator() {
+			snapshotInterval = n.snapshotInterval
+		}
+		if err := node.setupConfig(ctx, peers, snapshotInterval); err != nil {
 			return fmt.Errorf("failed to setup config for node %s: %w", node.name, err)
 		}
 		fmt.Printf("Node %s configuration completed\n", node.name)
@@ -104,50 +178,99 @@ func (n *CelestiaNetwork) SetupNetwork(ctx context.Context) error {
 		return fmt.Errorf("failed to export genesis: %w", err)
 	}
 
-	// To fix this issue:
-	// Error: error reading GenesisDoc at /root/.celestia-app/config/genesis.json: block.MaxBytes is too big. 128000000 > 104857600
-	genesisDoc.ConsensusParams.Block.MaxBytes = 104857600
+	// Serialize the genesis file once for all nodes
+	tmpDir, err := os.MkdirTemp("", "celestia-genesis-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	genesisPath := filepath.Join(tmpDir, "genesis.json")
+	if err := genesisDoc.SaveAs(genesisPath); err != nil {
+		return fmt.Errorf("failed to save genesis file: %w", err)
+	}
+	n.genesisJSON, err = os.ReadFile(genesisPath)
+	if err != nil {
+		return fmt.Errorf("failed to read genesis file: %w", err)
+	}
 
 	// Write genesis file to each node
 	fmt.Println("Distributing genesis file to nodes...")
 	for _, node := range n.nodes {
-		// Remove existing genesis file if it exists
-		remoteGenesisPath := filepath.Join(node.homeDir, "config", "genesis.json")
-		if err := node.sshManager.ExecuteCommand(node.publicIP, fmt.Sprintf("rm -f %s", remoteGenesisPath)); err != nil {
-			return fmt.Errorf("failed to remove existing genesis file: %w", err)
+		if err := node.writeGenesis(ctx, n.genesisJSON); err != nil {
+			return err
 		}
+	}
 
-		// Create a temporary directory for the genesis file
-		tmpDir, err := os.MkdirTemp("", "celestia-genesis-*")
-		if err != nil {
-			return fmt.Errorf("failed to create temp dir: %w", err)
+	// Validators keep their genesis account key so that they can send
+	// transactions, e.g. to fund validators joining later
+	for _, node := range n.nodes {
+		if !node.IsValidator() {
+			continue
 		}
-		defer os.RemoveAll(tmpDir)
-
-		// Write genesis file to temp directory
-		genesisPath := filepath.Join(tmpDir, "genesis.json")
-		if err := genesisDoc.SaveAs(genesisPath); err != nil {
-			return fmt.Errorf("failed to save genesis file: %w", err)
+		if err := node.importAccountKey(ctx, n.genesis.Keyring()); err != nil {
+			return fmt.Errorf("failed to import account key of node %s: %w", node.name, err)
 		}
+	}
 
-		// Read the genesis file content
-		genesisContent, err := os.ReadFile(genesisPath)
-		if err != nil {
-			return fmt.Errorf("failed to read genesis file: %w", err)
-		}
+	fmt.Println("Celestia network setup completed successfully")
+	return nil
+}
 
-		// Write genesis file to remote node
-		if err := node.sshManager.WriteToFile(node.publicIP, remoteGenesisPath, string(genesisContent)); err != nil {
-			return fmt.Errorf("failed to write genesis file to node %s: %w", node.name, err)
-		}
-		// Set correct permissions for genesis.json
-		if err := node.sshManager.ExecuteCommand(node.publicIP, fmt.Sprintf("chmod 644 %s", remoteGenesisPath)); err != nil {
-			return fmt.Errorf("failed to set permissions for genesis file: %w", err)
-		}
-		fmt.Printf("Genesis file written to node %s\n", node.name)
+// GenesisJSON returns the genesis file distributed by SetupNetwork
+func (n *CelestiaNetwork) GenesisJSON() []byte {
+	return n.genesisJSON
+}
+
+// writeGenesis replaces the genesis file of the node
+func (n *CelestiaNode) writeGenesis(ctx context.Context, genesisJSON []byte) error {
+	remoteGenesisPath := filepath.Join(n.homeDir, "config", "genesis.json")
+	if err := n.sshManager.WriteToFile(ctx, n.publicIP, remoteGenesisPath, string(genesisJSON), 0644); err != nil {
+		return fmt.Errorf("failed to write genesis file to node %s: %w", n.name, err)
 	}
+	// Set correct permissions for genesis.json
+	if err := n.sshManager.ExecuteCommand(ctx, n.publicIP, fmt.Sprintf("chmod 644 %s", remoteGenesisPath)); err != nil {
+		return fmt.Errorf("failed to set permissions for genesis file: %w", err)
+	}
+	fmt.Printf("Genesis file written to node %s\n", n.name)
+	return nil
+}
 
-	fmt.Println("Celestia network setup completed successfully")
+// importAccountKey imports the account key of the node from the genesis
+// keyring into the test keyring of the node
+func (n *CelestiaNode) importAccountKey(ctx context.Context, kr keyring.Keyring) error {
+	if err := importKey(ctx, n.sshManager, n.publicIP, n.homeDir, kr, n.name); err != nil {
+		return err
+	}
+	fmt.Printf("Account key imported on node %s\n", n.name)
+	return nil
+}
+
+// importKey copies a key from a local keyring into the test keyring of
+// celestia-appd on a host, replacing a key with the same name
+func importKey(ctx context.Context, sshManager *SSHManager, host, homeDir string, kr keyring.Keyring, name string) error {
+	passphrase := make([]byte, 16)
+	if _, err := rand.Read(passphrase); err != nil {
+		return fmt.Errorf("failed to generate passphrase: %w", err)
+	}
+	passphraseHex := hex.EncodeToString(passphrase)
+
+	armor, err := kr.ExportPrivKeyArmor(name, passphraseHex)
+	if err != nil {
+		return fmt.Errorf("failed to export key: %w", err)
+	}
+
+	remoteArmorPath := filepath.Join(homeDir, name+".armor")
+	if err := sshManager.WriteToFile(ctx, host, remoteArmorPath, armor, 0600); err != nil {
+		return fmt.Errorf("failed to write key: %w", err)
+	}
+
+	keys := fmt.Sprintf("%s keys --keyring-backend test --home %s", appdBinary, homeDir)
+	cmd := fmt.Sprintf("%s delete %s -y > /dev/null 2>&1; echo %s | %s import %s %s; status=$?; rm -f %s; exit $status",
+		keys, name, passphraseHex, keys, name, remoteArmorPath, remoteArmorPath)
+	if err := sshManager.ExecuteCommand(ctx, host, cmd); err != nil {
+		return fmt.Errorf("failed to import key: %w", err)
+	}
 	return nil
 }
 
@@ -159,7 +282,7 @@ func (n *CelestiaNode) setupNode(ctx context.Context) error {
 		filepath.Join(n.homeDir, "config"),
 		filepath.Join(n.homeDir, "data"),
 	} {
-		if err := n.sshManager.ExecuteCommand(n.publicIP, fmt.Sprintf("mkdir -p %s", dir)); err != nil {
+		if err := n.sshManager.ExecuteCommand(ctx, n.publicIP, fmt.Sprintf("mkdir -p %s", dir)); err != nil {
 			return fmt.Errorf("failed to create directory %s: %w", dir, err)
 		}
 	}
@@ -171,7 +294,7 @@ func (n *CelestiaNode) setupNode(ctx context.Context) error {
 		filepath.Join(n.homeDir, "config", "node_key.json"),
 	}
 	for _, file := range keyFiles {
-		if err := n.sshManager.ExecuteCommand(n.publicIP, fmt.Sprintf("rm -f %s", file)); err != nil {
+		if err := n.sshManager.ExecuteCommand(ctx, n.publicIP, fmt.Sprintf("rm -f %s", file)); err != nil {
 			return fmt.Errorf("failed to remove existing file %s: %w", file, err)
 		}
 	}
@@ -185,8 +308,9 @@ func (n *CelestiaNode) setupNode(ctx context.Context) error {
 	return nil
 }
 
-// setupConfig sets up the configuration files for a Celestia node
-func (n *CelestiaNode) setupConfig(peers []string) error {
+// setupConfig sets up the configuration files for a Celestia node. A non-zero
+// snapshot interval enables state sync snapshots in app.toml.
+func (n *CelestiaNode) setupConfig(ctx context.Context, peers []string, snapshotInterval uint64) error {
 	fmt.Printf("Creating configuration files for node %s...\n", n.name)
 
 	// Remove existing config files if they exist
@@ -195,7 +319,7 @@ func (n *CelestiaNode) setupConfig(peers []string) error {
 		filepath.Join(n.homeDir, "config", "app.toml"),
 	}
 	for _, file := range configFiles {
-		if err := n.sshManager.ExecuteCommand(n.publicIP, fmt.Sprintf("rm -f %s", file)); err != nil {
+		if err := n.sshManager.ExecuteCommand(ctx, n.publicIP, fmt.Sprintf("rm -f %s", file)); err != nil {
 			return fmt.Errorf("failed to remove existing config file %s: %w", file, err)
 		}
 	}
@@ -230,11 +354,11 @@ func (n *CelestiaNode) setupConfig(peers []string) error {
 
 	// Write config.toml to remote node
 	remoteConfigPath := filepath.Join(n.homeDir, "config", "config.toml")
-	if err := n.sshManager.WriteToFile(n.publicIP, remoteConfigPath, string(configContent)); err != nil {
+	if err := n.sshManager.WriteToFile(ctx, n.publicIP, remoteConfigPath, string(configContent), 0644); err != nil {
 		return fmt.Errorf("failed to write config file: %w", err)
 	}
 	// Set correct permissions for config.toml
-	if err := n.sshManager.ExecuteCommand(n.publicIP, fmt.Sprintf("chmod 644 %s", remoteConfigPath)); err != nil {
+	if err := n.sshManager.ExecuteCommand(ctx, n.publicIP, fmt.Sprintf("chmod 644 %s", remoteConfigPath)); err != nil {
 		return fmt.Errorf("failed to set permissions for config file: %w", err)
 	}
 	fmt.Printf("config.toml written to node %s\n", n.name)
@@ -244,6 +368,10 @@ func (n *CelestiaNode) setupConfig(peers []string) error {
 	srvCfg.BaseConfig.MinGasPrices = fmt.Sprintf("0.001%s", app.BondDenom)
 	srvCfg.GRPC.MaxRecvMsgSize = 128 * 1024 * 1024 // 128 MiB
 	srvCfg.GRPC.MaxSendMsgSize = 128 * 1024 * 1024 // 128 MiB
+	if snapshotInterval > 0 {
+		srvCfg.StateSync.SnapshotInterval = snapshotInterval
+		srvCfg.StateSync.SnapshotKeepRecent = 2
+	}
 
 	// Validate the configuration
 	if err := srvCfg.ValidateBasic(); err != nil {
@@ -262,11 +390,11 @@ func (n *CelestiaNode) setupConfig(peers []string) error {
 
 	// Write app.toml to remote node
 	remoteAppConfigPath := filepath.Join(n.homeDir, "config", "app.toml")
-	if err := n.sshManager.WriteToFile(n.publicIP, remoteAppConfigPath, string(appConfigContent)); err != nil {
+	if err := n.sshManager.WriteToFile(ctx, n.publicIP, remoteAppConfigPath, string(appConfigContent), 0644); err != nil {
 		return fmt.Errorf("failed to write app config file: %w", err)
 	}
 	// Set correct permissions for app.toml
-	if err := n.sshManager.ExecuteCommand(n.publicIP, fmt.Sprintf("chmod 644 %s", remoteAppConfigPath)); err != nil {
+	if err := n.sshManager.ExecuteCommand(ctx, n.publicIP, fmt.Sprintf("chmod 644 %s", remoteAppConfigPath)); err != nil {
 		return fmt.Errorf("failed to set permissions for app config file: %w", err)
 	}
 	fmt.Printf("app.toml written to node %s\n", n.name)
@@ -274,6 +402,30 @@ func (n *CelestiaNode) setupConfig(peers []string) error {
 	return nil
 }
 
+// Node returns the node with the given name, or nil if there is none
+func (n *CelestiaNetwork) Node(name string) *CelestiaNode {
+	for _, node := range n.nodes {
+		if node.name == name {
+			return node
+		}
+	}
+	return nil
+}
+
+// ValidatorPubKey returns the base64 encoded consensus public key of the node,
+// empty for full nodes
+func (n *CelestiaNode) ValidatorPubKey() string {
+	if !n.IsValidator() {
+		return ""
+	}
+	return base64.StdEncoding.EncodeToString(n.signerKey.PublicKey.Bytes())
+}
+
+// NetworkPubKey returns the base64 encoded public key of the node's P2P identity
+func (n *CelestiaNode) NetworkPubKey() string {
+	return base64.StdEncoding.EncodeToString(n.networkKey.PublicKey.Bytes())
+}
+
 // AddressP2P returns the P2P address of the node
 func (n *CelestiaNode) AddressP2P() string {
 	return fmt.Sprintf("%x@%s:26656", n.networkKey.PublicKey.Address().Bytes(), n.publicIP)
@@ -299,6 +451,12 @@ func (n *CelestiaNode) copyNodeKeys(ctx context.Context) error {
 		}
 	}
 
+	// Full nodes only get a network key, the validator key is generated locally
+	// by celestia-appd and never used
+	if !n.IsValidator() {
+		return n.copyNetworkKey(ctx, tmpDir)
+	}
+
 	// Set up paths for validator files
 	signerKeyPath := filepath.Join(tmpDir, "config", "priv_validator_key.json")
 	pvStatePath := filepath.Join(tmpDir, "data", "priv_validator_state.json")
@@ -321,22 +479,23 @@ func (n *CelestiaNode) copyNodeKeys(ctx context.Context) error {
 
 	// Write signer key to remote node
 	remoteSignerKeyPath := filepath.Join(n.homeDir, "config", "priv_validator_key.json")
-	if err := n.sshManager.WriteToFile(n.publicIP, remoteSignerKeyPath, string(signerKeyContent)); err != nil {
+	if err := n.sshManager.WriteToFile(ctx, n.publicIP, remoteSignerKeyPath, string(signerKeyContent), 0600); err != nil {
 		return fmt.Errorf("failed to write signer key: %w", err)
 	}
-	// Set correct permissions for priv_validator_key.json
-	if err := n.sshManager.ExecuteCommand(n.publicIP, fmt.Sprintf("chmod 600 %s", remoteSignerKeyPath)); err != nil {
-		return fmt.Errorf("failed to set permissions for signer key: %w", err)
-	}
 	fmt.Printf("Validator key written to node %s\n", n.name)
 
 	// Write validator state to remote node
 	remotePvStatePath := filepath.Join(n.homeDir, "data", "priv_validator_state.json")
-	if err := n.sshManager.WriteToFile(n.publicIP, remotePvStatePath, string(pvStateContent)); err != nil {
+	if err := n.sshManager.WriteToFile(ctx, n.publicIP, remotePvStatePath, string(pvStateContent), 0600); err != nil {
 		return fmt.Errorf("failed to write validator state file: %w", err)
 	}
 	fmt.Printf("Validator state written to node %s\n", n.name)
 
+	return n.copyNetworkKey(ctx, tmpDir)
+}
+
+// copyNetworkKey writes the network key to tmpDir and copies it to the remote instance
+func (n *CelestiaNode) copyNetworkKey(ctx context.Context, tmpDir string) error {
 	// Write network key
 	remoteNetworkKeyPath := filepath.Join(n.homeDir, "config", "node_key.json")
 	localNodeKeyPath := filepath.Join(tmpDir, "config", "node_key.json")
@@ -346,11 +505,11 @@ func (n *CelestiaNode) copyNodeKeys(ctx context.Context) error {
 	}
 
 	// Copy node key to remote node
-	if err := n.sshManager.CopyFile(n.publicIP, localNodeKeyPath, remoteNetworkKeyPath); err != nil {
+	if err := n.sshManager.CopyFile(ctx, n.publicIP, localNodeKeyPath, remoteNetworkKeyPath); err != nil {
 		return fmt.Errorf("failed to copy network key: %w", err)
 	}
 	// Set correct permissions for node_key.json
-	if err := n.sshManager.ExecuteCommand(n.publicIP, fmt.Sprintf("chmod 600 %s", remoteNetworkKeyPath)); err != nil {
+	if err := n.sshManager.ExecuteCommand(ctx, n.publicIP, fmt.Sprintf("chmod 600 %s", remoteNetworkKeyPath)); err != nil {
 		return fmt.Errorf("failed to set permissions for node key: %w", err)
 	}
 	fmt.Printf("Network key written to node %s\n", n.name)
@@ -360,15 +519,13 @@ func (n *CelestiaNode) copyNodeKeys(ctx context.Context) error {
 
 // GenesisValidator returns the genesis validator configuration for a node
 func (n *CelestiaNode) GenesisValidator() genesis.Validator {
-	initialTokens := int64(1e16)
-	stakeTokens := int64(1e12)
 	return genesis.Validator{
 		KeyringAccount: genesis.KeyringAccount{
 			Name:          n.name,
-			InitialTokens: initialTokens,
+			InitialTokens: n.tokens.balance,
 		},
 		ConsensusKey: n.signerKey.PrivateKey,
 		NetworkKey:   n.networkKey.PrivateKey,
-		Stake:        stakeTokens,
+		Stake:        n.tokens.stake,
 	}
 }
@@ -0,0 +1,222 @@
+package manager
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/celestiaorg/talis-test/config"
+)
+
+// EnvironmentInfo summarizes an environment that has state
+type EnvironmentInfo struct {
+	Name      string `json:"name"`
+	Current   bool   `json:"current"`
+	Instances int    `json:"instances"`
+}
+
+// baseDir returns the directory holding the talis-test state
+func baseDir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".talis-test"), nil
+}
+
+// EnvironmentDir returns the directory holding the state of an environment.
+// The default environment uses the base directory so that state from before
+// environments existed keeps working.
+func EnvironmentDir(name string) (string, error) {
+	dir, err := baseDir()
+	if err != nil {
+		return "", err
+	}
+	if name == "" || name == config.DefaultEnvironment {
+		return dir, nil
+	}
+	return filepath.Join(dir, "envs", name), nil
+}
+
+// genesisPath returns the path of the local copy of the genesis file of the
+// configured environment
+func (m *TalisManager) genesisPath() (string, error) {
+	dir, err := EnvironmentDir(m.config.Environment)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "genesis.json"), nil
+}
+
+// currentEnvironmentPath returns the path of the file recording the selected environment
+func currentEnvironmentPath() (string, error) {
+	dir, err := baseDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "environment"), nil
+}
+
+// CurrentEnvironment returns the environment selected with UseEnvironment, or
+// the default environment if none was selected
+func CurrentEnvironment() (string, error) {
+	path, err := currentEnvironmentPath()
+	if err != nil {
+		return "", err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return config.DefaultEnvironment, nil
+		}
+		return "", fmt.Errorf("failed to read current environment: %w", err)
+	}
+
+	name := strings.TrimSpace(string(data))
+	if name == "" {
+		return config.DefaultEnvironment, nil
+	}
+	return name, nil
+}
+
+// UseEnvironment selects the environment used by commands that do not name one
+func UseEnvironment(name string) error {
+	if err := config.ValidateEnvironmentName(name); err != nil {
+		return err
+	}
+
+	path, err := currentEnvironmentPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, []byte(name+"\n"), 0644)
+}
+
+// ListEnvironments returns the environments that have state, including the
+// current one
+func ListEnvironments() ([]EnvironmentInfo, error) {
+	current, err := CurrentEnvironment()
+	if err != nil {
+		return nil, err
+	}
+
+	names := map[string]bool{current: true}
+	dir, err := baseDir()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(filepath.Join(dir, "state.json")); err == nil {
+		names[config.DefaultEnvironment] = true
+	}
+	entries, err := os.ReadDir(filepath.Join(dir, "envs"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to list environments: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			names[entry.Name()] = true
+		}
+	}
+
+	envs := make([]EnvironmentInfo, 0, len(names))
+	for name := range names {
+		count, err := environmentInstances(name)
+		if err != nil {
+			return nil, err
+		}
+		envs = append(envs, EnvironmentInfo{Name: name, Current: name == current, Instances: count})
+	}
+	sort.Slice(envs, func(i, j int) bool {
+		return envs[i].Name < envs[j].Name
+	})
+	return envs, nil
+}
+
+// environmentInstances returns the number of instances in the state of an environment
+func environmentInstances(name string) (int, error) {
+	dir, err := EnvironmentDir(name)
+	if err != nil {
+		return 0, err
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "state.json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	var state State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return 0, fmt.Errorf("failed to parse state of environment %s: %w", name, err)
+	}
+
+	count := 0
+	for _, instances := range state.Instances {
+		count += len(instances)
+	}
+	return count, nil
+}
+
+// RemoveEnvironmentState deletes the state of the configured environment,
+// including its backups. The instances must have been deleted before.
+func (m *TalisManager) RemoveEnvironmentState() error {
+	m.stateMu.Lock()
+	defer m.stateMu.Unlock()
+
+	for project, instances := range m.state.Instances {
+		if len(instances) > 0 {
+			return fmt.Errorf("environment %s still has %d instances in project %s", m.config.Environment, len(instances), project)
+		}
+	}
+
+	statePath, err := m.statePath()
+	if err != nil {
+		return err
+	}
+
+	genesisPath, err := m.genesisPath()
+	if err != nil {
+		return err
+	}
+
+	paths := []string{statePath, genesisPath}
+	for i := 1; i <= stateBackups; i++ {
+		paths = append(paths, fmt.Sprintf("%s.%d", statePath, i))
+	}
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+	}
+
+	accountsDir, err := AccountsDir(m.config.Environment)
+	if err != nil {
+		return err
+	}
+	keysDir, err := KeysDir(m.config.Environment)
+	if err != nil {
+		return err
+	}
+	for _, dir := range []string{accountsDir, keysDir} {
+		if err := os.RemoveAll(dir); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", dir, err)
+		}
+	}
+
+	// Only the default environment shares its directory with other files
+	if m.config.Environment != "" && m.config.Environment != config.DefaultEnvironment {
+		if err := os.RemoveAll(filepath.Dir(statePath)); err != nil {
+			return fmt.Errorf("failed to remove environment directory: %w", err)
+		}
+	}
+	return nil
+}
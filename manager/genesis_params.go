@@ -0,0 +1,101 @@
+package manager
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/celestiaorg/celestia-app/v3/pkg/appconsts"
+	blobtypes "github.com/celestiaorg/celestia-app/v3/x/blob/types"
+	"github.com/celestiaorg/talis-test/config"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	govtypes "github.com/cosmos/cosmos-sdk/x/gov/types"
+	govv1 "github.com/cosmos/cosmos-sdk/x/gov/types/v1"
+	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+	coretypes "github.com/tendermint/tendermint/types"
+)
+
+// ApplyGenesisParams sets the genesis time, consensus parameters and module
+// parameters of the network. Zero values keep the celestia-app defaults.
+func (n *CelestiaNetwork) ApplyGenesisParams(params config.GenesisParams) error {
+	if !params.GenesisTime.IsZero() {
+		n.genesis.WithGenesisTime(params.GenesisTime)
+	}
+
+	consensus := *n.genesis.ConsensusParams
+	if params.AppVersion != 0 {
+		if params.AppVersion > appconsts.LatestVersion {
+			return fmt.Errorf("app version %d is not supported, the latest version is %d", params.AppVersion, appconsts.LatestVersion)
+		}
+		consensus.Version.AppVersion = params.AppVersion
+	}
+	if params.BlockMaxBytes != 0 {
+		consensus.Block.MaxBytes = params.BlockMaxBytes
+	}
+	if params.BlockMaxGas != 0 {
+		consensus.Block.MaxGas = params.BlockMaxGas
+	}
+	if params.EvidenceMaxAgeNumBlocks != 0 {
+		consensus.Evidence.MaxAgeNumBlocks = params.EvidenceMaxAgeNumBlocks
+	}
+	if params.EvidenceMaxAgeDuration != 0 {
+		consensus.Evidence.MaxAgeDuration = params.EvidenceMaxAgeDuration
+	}
+	if params.EvidenceMaxBytes != 0 {
+		consensus.Evidence.MaxBytes = params.EvidenceMaxBytes
+	}
+	if len(params.ValidatorPubKeyTypes) > 0 {
+		consensus.Validator.PubKeyTypes = params.ValidatorPubKeyTypes
+	}
+	if err := coretypes.ValidateConsensusParams(consensus); err != nil {
+		return fmt.Errorf("invalid consensus params: %w", err)
+	}
+	n.genesis.WithConsensusParams(&consensus)
+
+	cdc := n.genesis.EncodingConfig().Codec
+	if params.UnbondingTime != 0 || params.MinCommissionRate != "" {
+		var minCommissionRate sdk.Dec
+		if params.MinCommissionRate != "" {
+			rate, err := sdk.NewDecFromStr(params.MinCommissionRate)
+			if err != nil {
+				return fmt.Errorf("invalid min commission rate %q: %w", params.MinCommissionRate, err)
+			}
+			minCommissionRate = rate
+		}
+
+		n.genesis.WithModifiers(func(state map[string]json.RawMessage) map[string]json.RawMessage {
+			var stakingState stakingtypes.GenesisState
+			cdc.MustUnmarshalJSON(state[stakingtypes.ModuleName], &stakingState)
+			if params.UnbondingTime != 0 {
+				stakingState.Params.UnbondingTime = params.UnbondingTime
+			}
+			if params.MinCommissionRate != "" {
+				stakingState.Params.MinCommissionRate = minCommissionRate
+			}
+			state[stakingtypes.ModuleName] = cdc.MustMarshalJSON(&stakingState)
+			return state
+		})
+	}
+
+	if params.VotingPeriod != 0 {
+		n.genesis.WithModifiers(func(state map[string]json.RawMessage) map[string]json.RawMessage {
+			var govState govv1.GenesisState
+			cdc.MustUnmarshalJSON(state[govtypes.ModuleName], &govState)
+			votingPeriod := params.VotingPeriod
+			govState.VotingParams = &govv1.VotingParams{VotingPeriod: &votingPeriod}
+			state[govtypes.ModuleName] = cdc.MustMarshalJSON(&govState)
+			return state
+		})
+	}
+
+	if params.GovMaxSquareSize != 0 {
+		n.genesis.WithModifiers(func(state map[string]json.RawMessage) map[string]json.RawMessage {
+			var blobState blobtypes.GenesisState
+			cdc.MustUnmarshalJSON(state[blobtypes.ModuleName], &blobState)
+			blobState.Params.GovMaxSquareSize = params.GovMaxSquareSize
+			state[blobtypes.ModuleName] = cdc.MustMarshalJSON(&blobState)
+			return state
+		})
+	}
+
+	return nil
+}
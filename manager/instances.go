@@ -0,0 +1,131 @@
+package manager
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/celestiaorg/talis-test/config"
+)
+
+// MismatchKind classifies a difference between the configuration and the state
+type MismatchKind string
+
+const (
+	// MismatchMissing is a configured instance that was not created yet
+	MismatchMissing MismatchKind = "missing"
+	// MismatchUnknown is an instance in state that is not in the configuration
+	MismatchUnknown MismatchKind = "unknown"
+	// MismatchChanged is an instance whose configuration changed since it was created
+	MismatchChanged MismatchKind = "changed"
+)
+
+// InstanceMismatch is a difference between a configured and a created instance
+type InstanceMismatch struct {
+	Name   string
+	Kind   MismatchKind
+	Reason string
+}
+
+// InstanceMismatchError is returned when the instances in state do not match
+// the configuration
+type InstanceMismatchError struct {
+	Mismatches []InstanceMismatch
+}
+
+// Error implements the error interface
+func (e *InstanceMismatchError) Error() string {
+	lines := make([]string, 0, len(e.Mismatches))
+	for _, mismatch := range e.Mismatches {
+		lines = append(lines, fmt.Sprintf("  %s (%s): %s", mismatch.Name, mismatch.Kind, mismatch.Reason))
+	}
+	return fmt.Sprintf("instances in state do not match the configuration:\n%s\nrun plan to review the changes and apply to make them", strings.Join(lines, "\n"))
+}
+
+// instanceName returns the name of the instance created for a definition.
+// Talis appends the index of the instance within its request to the name.
+func instanceName(def config.InstanceDefinition) string {
+	return def.Name + "-0"
+}
+
+// configuredDefinition returns the configured definition of the instance
+// with the given name
+func (m *TalisManager) configuredDefinition(name string) (config.InstanceDefinition, bool) {
+	for _, def := range m.config.Instances {
+		if instanceName(def) == name {
+			return def, true
+		}
+	}
+	return config.InstanceDefinition{}, false
+}
+
+// matchInstances joins the configured definitions with the instances of the
+// current project in state by name and returns the differences
+func (m *TalisManager) matchInstances() []InstanceMismatch {
+	created := make(map[string]InstanceInfo)
+	for _, instance := range m.state.Instances[m.config.ProjectName] {
+		created[instance.Name] = instance
+	}
+
+	var mismatches []InstanceMismatch
+	configured := make(map[string]bool)
+	for _, def := range m.config.Instances {
+		name := instanceName(def)
+		configured[name] = true
+
+		instance, ok := created[name]
+		if !ok {
+			mismatches = append(mismatches, InstanceMismatch{
+				Name:   name,
+				Kind:   MismatchMissing,
+				Reason: "configured but not created, run infra",
+			})
+			continue
+		}
+
+		if changes := definitionChanges(instance, def); len(changes) > 0 {
+			mismatches = append(mismatches, InstanceMismatch{
+				Name:   name,
+				Kind:   MismatchChanged,
+				Reason: "created with a different configuration: " + strings.Join(changes, ", "),
+			})
+		}
+	}
+
+	for _, instance := range m.state.Instances[m.config.ProjectName] {
+		if !configured[instance.Name] {
+			mismatches = append(mismatches, InstanceMismatch{
+				Name:   instance.Name,
+				Kind:   MismatchUnknown,
+				Reason: "created but no longer configured",
+			})
+		}
+	}
+
+	return mismatches
+}
+
+// definitionChanges returns the differences between the configuration an
+// instance was created with and its current definition that require the
+// instance to be recreated
+func definitionChanges(instance InstanceInfo, def config.InstanceDefinition) []string {
+	var changes []string
+	if instance.Role != def.Role {
+		changes = append(changes, fmt.Sprintf("role %q -> %q", instance.Role, def.Role))
+	}
+	if instance.Region != def.InstanceConfig.Region {
+		changes = append(changes, fmt.Sprintf("region %q -> %q", instance.Region, def.InstanceConfig.Region))
+	}
+	if instance.Size != def.InstanceConfig.Size {
+		changes = append(changes, fmt.Sprintf("size %q -> %q", instance.Size, def.InstanceConfig.Size))
+	}
+	return changes
+}
+
+// checkInstances returns an InstanceMismatchError if the instances in state do
+// not match the configuration
+func (m *TalisManager) checkInstances() error {
+	if mismatches := m.matchInstances(); len(mismatches) > 0 {
+		return &InstanceMismatchError{Mismatches: mismatches}
+	}
+	return nil
+}
@@ -1,10 +1,11 @@
 package manager
 
 import (
+	crand "crypto/rand"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"io"
-	"math/rand"
 
 	"github.com/tendermint/tendermint/crypto"
 	"github.com/tendermint/tendermint/crypto/ed25519"
@@ -75,22 +76,38 @@ func (k *keyPair) ToJSON() (string, error) {
 	return string(jsonBytes), nil
 }
 
+// keyGenerator derives keys from a source of randomness, or from a fixed
+// secret if it is seeded
 type keyGenerator struct {
-	random *rand.Rand
+	random io.Reader
+	secret []byte
 }
 
-func newKeyGenerator(seed int64) *keyGenerator {
+// newRandomKeyGenerator returns a generator of cryptographically secure keys
+func newRandomKeyGenerator() *keyGenerator {
 	return &keyGenerator{
-		random: rand.New(rand.NewSource(seed)), //nolint:gosec
+		random: crand.Reader,
 	}
 }
 
-func (g *keyGenerator) Generate(keyType keyType) *keyPair {
-	seed := make([]byte, ed25519.SeedSize)
+// newSeededKeyGenerator returns a generator of a reproducible key. The key is
+// derived from the SHA-256 hash of the seed and the name, so every key of a
+// seeded network is its own regardless of the order keys are generated in. A
+// seeded generator returns the same key on every call.
+func newSeededKeyGenerator(seed int64, name string) *keyGenerator {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%d/%s", seed, name)))
+	return &keyGenerator{
+		secret: sum[:],
+	}
+}
 
-	_, err := io.ReadFull(g.random, seed)
-	if err != nil {
-		panic(err) // this shouldn't happen
+func (g *keyGenerator) Generate(keyType keyType) *keyPair {
+	seed := g.secret
+	if seed == nil {
+		seed = make([]byte, ed25519.SeedSize)
+		if _, err := io.ReadFull(g.random, seed); err != nil {
+			panic(err) // this shouldn't happen
+		}
 	}
 
 	var privKey crypto.PrivKey
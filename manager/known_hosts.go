@@ -0,0 +1,151 @@
+package manager
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// HostKeyMismatchError is returned when a host presents a different key than
+// the one recorded on first connect
+type HostKeyMismatchError struct {
+	Host     string
+	Got      string
+	Recorded []string
+}
+
+// Error implements the error interface
+func (e *HostKeyMismatchError) Error() string {
+	return fmt.Sprintf("host key of %s changed: got %s, recorded %s. The instance may have been rebuilt or its IP reused; "+
+		"run the trust command for the instance to accept the new key",
+		e.Host, e.Got, strings.Join(e.Recorded, ", "))
+}
+
+// hostKeyCallback verifies host keys against the managed known_hosts file.
+// Keys of unknown hosts are recorded on first connect (trust on first use).
+func (s *SSHManager) hostKeyCallback(hostname string, remote net.Addr, key ssh.PublicKey) error {
+	s.knownHostsMu.Lock()
+	defer s.knownHostsMu.Unlock()
+
+	if err := ensureFile(s.config.KnownHostsPath); err != nil {
+		return err
+	}
+
+	check, err := knownhosts.New(s.config.KnownHostsPath)
+	if err != nil {
+		return fmt.Errorf("failed to read known hosts %s: %w", s.config.KnownHostsPath, err)
+	}
+
+	err = check(hostname, remote, key)
+	var keyErr *knownhosts.KeyError
+	if err == nil || !errors.As(err, &keyErr) {
+		return err
+	}
+
+	if len(keyErr.Want) > 0 {
+		recorded := make([]string, 0, len(keyErr.Want))
+		for _, want := range keyErr.Want {
+			recorded = append(recorded, ssh.FingerprintSHA256(want.Key))
+		}
+		return &HostKeyMismatchError{
+			Host:     knownhosts.Normalize(hostname),
+			Got:      ssh.FingerprintSHA256(key),
+			Recorded: recorded,
+		}
+	}
+
+	// Unknown host, record its key
+	file, err := os.OpenFile(s.config.KnownHostsPath, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open known hosts %s: %w", s.config.KnownHostsPath, err)
+	}
+	defer file.Close()
+
+	if _, err := fmt.Fprintln(file, knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key)); err != nil {
+		return fmt.Errorf("failed to record host key of %s: %w", hostname, err)
+	}
+	log.Printf("Recorded host key %s for %s", ssh.FingerprintSHA256(key), knownhosts.Normalize(hostname))
+
+	return nil
+}
+
+// Forget removes the recorded host key of the host and closes its pooled
+// connection
+func (s *SSHManager) Forget(host string) error {
+	s.dropHost(host)
+
+	s.knownHostsMu.Lock()
+	defer s.knownHostsMu.Unlock()
+
+	data, err := os.ReadFile(s.config.KnownHostsPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read known hosts %s: %w", s.config.KnownHostsPath, err)
+	}
+
+	address := knownhosts.Normalize(host + ":22")
+	var kept bytes.Buffer
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if fields := strings.Fields(line); len(fields) > 0 && !strings.HasPrefix(fields[0], "#") {
+			matches := false
+			for _, pattern := range strings.Split(fields[0], ",") {
+				if pattern == address {
+					matches = true
+					break
+				}
+			}
+			if matches {
+				continue
+			}
+		}
+		kept.WriteString(line + "\n")
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read known hosts %s: %w", s.config.KnownHostsPath, err)
+	}
+
+	if err := os.WriteFile(s.config.KnownHostsPath, kept.Bytes(), 0600); err != nil {
+		return fmt.Errorf("failed to write known hosts %s: %w", s.config.KnownHostsPath, err)
+	}
+	return nil
+}
+
+// Trust replaces the recorded host key of the host with the key it currently
+// presents
+func (s *SSHManager) Trust(ctx context.Context, host string) error {
+	if err := s.Forget(host); err != nil {
+		return err
+	}
+
+	// Connecting records the new key
+	if _, err := s.client(ctx, host); err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", host, err)
+	}
+	return nil
+}
+
+// ensureFile creates an empty file and its directory if it does not exist
+func ensureFile(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	return file.Close()
+}
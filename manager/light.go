@@ -0,0 +1,266 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/celestiaorg/talis-test/config"
+)
+
+const (
+	// bridgeNodeStore is the node store of bridge nodes on the instances
+	bridgeNodeStore = "~/.celestia-bridge"
+	// lightNodeStore is the node store of light nodes on the instances
+	lightNodeStore = "~/.celestia-light"
+	// nodeP2PPort is the libp2p port celestia-node listens on
+	nodeP2PPort = 2121
+)
+
+// SamplingStatus is the data availability sampling progress of a light node
+type SamplingStatus struct {
+	Name               string `json:"name"`
+	PublicIP           string `json:"public_ip"`
+	HeadOfSampledChain uint64 `json:"head_of_sampled_chain"`
+	HeadOfCatchup      uint64 `json:"head_of_catchup"`
+	NetworkHeadHeight  uint64 `json:"network_head_height"`
+	CatchUpDone        bool   `json:"catch_up_done"`
+	IsRunning          bool   `json:"is_running"`
+	Error              string `json:"error,omitempty"`
+}
+
+// SetupLightNodes initializes the light node stores and runs the light nodes
+// as systemd services. The bridges of the deployment are used as
+// bootstrappers and the latest block of a validator as the trusted hash.
+func (m *TalisManager) SetupLightNodes(ctx context.Context, chainID string) error {
+	// Load state
+	state, err := m.LoadState()
+	if err != nil {
+		return fmt.Errorf("failed to load state: %w", err)
+	}
+	m.state = state
+
+	// Check instances against configuration
+	if err := m.checkInstances(); err != nil {
+		return err
+	}
+
+	lights := m.instancesByRole(config.LightNode)
+	if len(lights) == 0 {
+		log.Println("No light nodes configured")
+		return nil
+	}
+
+	var validators []InstanceInfo
+	for _, validator := range m.instancesByRole(config.ValidatorNode) {
+		if validator.PublicIP != "" {
+			validators = append(validators, validator)
+		}
+	}
+	if len(validators) == 0 {
+		return fmt.Errorf("no validators with a public IP available for %d light nodes", len(lights))
+	}
+
+	bootstrappers, err := m.bridgeMultiaddrs(ctx)
+	if err != nil {
+		return err
+	}
+	if len(bootstrappers) == 0 {
+		return fmt.Errorf("no bridge nodes available as bootstrappers for %d light nodes", len(lights))
+	}
+	log.Printf("Using %d bridge nodes as bootstrappers", len(bootstrappers))
+
+	genesisHash, err := waitForGenesisHash(ctx, validators[0].PublicIP, m.config.Timeouts.ChainStart)
+	if err != nil {
+		return fmt.Errorf("failed to get genesis hash: %w", err)
+	}
+
+	trustedHash, err := blockHash(ctx, validators[0].PublicIP, 0)
+	if err != nil {
+		return fmt.Errorf("failed to get trusted hash: %w", err)
+	}
+	log.Printf("Using trusted hash %s from validator %s", trustedHash, validators[0].Name)
+
+	// Create a semaphore to limit concurrent operations
+	sem := make(chan struct{}, 10)
+	errChan := make(chan error, len(lights))
+	var wg sync.WaitGroup
+
+	for _, light := range lights {
+		if light.PublicIP == "" {
+			log.Printf("Skipping instance %d: no public IP", light.ID)
+			continue
+		}
+
+		wg.Add(1)
+		go func(inst InstanceInfo) {
+			defer wg.Done()
+
+			// Acquire semaphore
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			log.Printf("Setting up light node on instance %s (%s)...", inst.Name, inst.PublicIP)
+
+			// Copy the service setup script to the remote machine
+			if err := m.sshManager.CopyFile(ctx, inst.PublicIP, "scripts/setup_celestia_light_service.sh", "setup_celestia_light_service.sh"); err != nil {
+				errChan <- fmt.Errorf("failed to copy light setup script to instance %s (%s): %w", inst.Name, inst.PublicIP, err)
+				return
+			}
+
+			// Make the script executable and run it
+			cmd := fmt.Sprintf("chmod +x setup_celestia_light_service.sh && ./setup_celestia_light_service.sh %s %s %s %s",
+				chainID, genesisHash, strings.Join(bootstrappers, ","), trustedHash)
+			if err := m.runStreaming(ctx, inst, cmd); err != nil {
+				errChan <- fmt.Errorf("failed to execute light setup script on instance %s (%s): %w", inst.Name, inst.PublicIP, err)
+				return
+			}
+
+			log.Printf("Successfully set up light node on instance %s (%s)", inst.Name, inst.PublicIP)
+			if err := m.markStage(inst.Name, StageStarted); err != nil {
+				errChan <- err
+			}
+		}(light)
+	}
+
+	// Wait for all goroutines to complete
+	wg.Wait()
+	close(errChan)
+
+	// Check for any errors
+	for err := range errChan {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// StopLightNodes stops the light node service on all light instances
+func (m *TalisManager) StopLightNodes(ctx context.Context) error {
+	return m.stopService(ctx, "celestia-light", func(inst InstanceInfo) bool {
+		return inst.Role == config.LightNode
+	})
+}
+
+// bridgeMultiaddrs returns the libp2p multiaddrs of the running bridge nodes
+func (m *TalisManager) bridgeMultiaddrs(ctx context.Context) ([]string, error) {
+	var addrs []string
+	for _, bridge := range m.instancesByRole(config.BridgeNode) {
+		if bridge.PublicIP == "" {
+			continue
+		}
+
+		// The bridge may still be starting up, so retry for a while
+		peerID, err := m.waitForPeerID(ctx, bridge.PublicIP, bridgeNodeStore, 2*time.Minute)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get peer ID of bridge %s (%s): %w", bridge.Name, bridge.PublicIP, err)
+		}
+
+		addrs = append(addrs, fmt.Sprintf("/ip4/%s/tcp/%d/p2p/%s", bridge.PublicIP, nodeP2PPort, peerID))
+	}
+	return addrs, nil
+}
+
+// waitForPeerID retries nodePeerID until it succeeds or the timeout expires
+func (m *TalisManager) waitForPeerID(ctx context.Context, host, nodeStore string, timeout time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		peerID, err := m.nodePeerID(ctx, host, nodeStore)
+		if err == nil {
+			return peerID, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("peer ID not available after %v: %w", timeout, err)
+		case <-ticker.C:
+		}
+	}
+}
+
+// nodePeerID returns the libp2p peer ID of the celestia node running on host
+func (m *TalisManager) nodePeerID(ctx context.Context, host, nodeStore string) (string, error) {
+	output, err := m.sshManager.ExecuteCommandWithOutput(ctx, host, "celestia p2p info --node.store "+nodeStore)
+	if err != nil {
+		return "", err
+	}
+
+	var resp struct {
+		Result struct {
+			ID string `json:"ID"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal([]byte(output), &resp); err != nil {
+		return "", fmt.Errorf("failed to decode p2p info: %w", err)
+	}
+	if resp.Result.ID == "" {
+		return "", fmt.Errorf("empty peer ID in p2p info")
+	}
+	return resp.Result.ID, nil
+}
+
+// LightNodeStatus returns the sampling status of every light node
+func (m *TalisManager) LightNodeStatus(ctx context.Context) ([]SamplingStatus, error) {
+	// Load state
+	state, err := m.LoadState()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load state: %w", err)
+	}
+	m.state = state
+
+	lights := m.instancesByRole(config.LightNode)
+	statuses := make([]SamplingStatus, len(lights))
+
+	// Create a semaphore to limit concurrent operations
+	sem := make(chan struct{}, 10)
+	var wg sync.WaitGroup
+
+	for i, light := range lights {
+		statuses[i] = SamplingStatus{Name: light.Name, PublicIP: light.PublicIP}
+		if light.PublicIP == "" {
+			statuses[i].Error = "no public IP"
+			continue
+		}
+
+		wg.Add(1)
+		go func(status *SamplingStatus) {
+			defer wg.Done()
+
+			// Acquire semaphore
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			output, err := m.sshManager.ExecuteCommandWithOutput(ctx, status.PublicIP, "celestia das sampling-stats --node.store "+lightNodeStore)
+			if err != nil {
+				// Only keep the first line, the rest is the command output
+				status.Error = strings.SplitN(err.Error(), "\n", 2)[0]
+				return
+			}
+
+			var resp struct {
+				Result json.RawMessage `json:"result"`
+			}
+			if err := json.Unmarshal([]byte(output), &resp); err != nil {
+				status.Error = fmt.Sprintf("failed to decode sampling stats: %v", err)
+				return
+			}
+			if err := json.Unmarshal(resp.Result, status); err != nil {
+				status.Error = fmt.Sprintf("failed to decode sampling stats: %v", err)
+			}
+		}(&statuses[i])
+	}
+
+	wg.Wait()
+	return statuses, nil
+}
@@ -2,27 +2,37 @@ package manager
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
-	"strconv"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/celestiaorg/talis-test/config"
 	"github.com/celestiaorg/talis/pkg/api/v1/client"
-	"github.com/celestiaorg/talis/pkg/api/v1/handlers"
 	"github.com/celestiaorg/talis/pkg/db/models"
 	"github.com/celestiaorg/talis/pkg/types"
+	"github.com/gofrs/flock"
 )
 
+// stateSyncSnapshotInterval is the block interval at which validators take
+// state sync snapshots when full nodes are configured to state sync
+const stateSyncSnapshotInterval = 100
+
 // TalisManager manages the Talis client and operations
 type TalisManager struct {
-	client     client.Client
-	config     config.Config
-	state      State
+	provider  Provider
+	config    config.Config
+	state     State
+	stateLock *flock.Flock
+	// stateMu serializes the state updates of concurrent stage workers
+	stateMu    sync.Mutex
 	sshManager *SSHManager
+	// checkSSH checks that a host accepts SSH connections, tests replace it
+	checkSSH func(ctx context.Context, host string) error
 }
 
 // NewTalisManager creates a new TalisManager instance
@@ -36,18 +46,58 @@ func NewTalisManager(config config.Config) (*TalisManager, error) {
 		return nil, fmt.Errorf("failed to create client: %w", err)
 	}
 
+	return NewTalisManagerWithProvider(config, NewTalisProvider(client))
+}
+
+// NewTalisManagerWithProvider creates a new TalisManager that manages its
+// instances through the given provider
+func NewTalisManagerWithProvider(config config.Config, provider Provider) (*TalisManager, error) {
+	knownHostsPath, err := KnownHostsPath()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get known hosts path: %w", err)
+	}
+
 	sshManager := NewSSHManager(SSHConfig{
-		Username:   config.SSHUsername,
-		PrivateKey: config.SSHPrivateKeyPath,
+		Username:       config.SSHUsername,
+		PrivateKey:     config.SSHPrivateKeyPath,
+		KnownHostsPath: knownHostsPath,
+		DialTimeout:    config.Timeouts.SSHDial,
+		CommandTimeout: config.Timeouts.Command,
 	})
 
 	return &TalisManager{
-		client:     client,
+		provider:   provider,
 		config:     config,
 		sshManager: sshManager,
+		checkSSH:   sshManager.CheckConnection,
 	}, nil
 }
 
+// runStreaming runs a command on an instance, streaming its output to the
+// console prefixed with the instance name
+func (m *TalisManager) runStreaming(ctx context.Context, inst InstanceInfo, command string) error {
+	result, err := m.sshManager.RunCommand(ctx, inst.PublicIP, command, RunOptions{
+		Stream: true,
+		Prefix: inst.Name,
+	})
+	if err != nil {
+		// The output has already been streamed, keep the error short
+		var cmdErr *CommandError
+		if errors.As(err, &cmdErr) {
+			return fmt.Errorf("command exited with status %d after %s", cmdErr.Result.ExitCode, cmdErr.Result.Duration.Round(time.Second))
+		}
+		return err
+	}
+
+	log.Printf("Command on instance %s finished in %s", inst.Name, result.Duration.Round(time.Second))
+	return nil
+}
+
+// Close releases the SSH connections and the state lock held by the manager
+func (m *TalisManager) Close() error {
+	return errors.Join(m.sshManager.Close(), m.unlockState())
+}
+
 // PrepareInfrastructure sets up the required infrastructure
 func (m *TalisManager) PrepareInfrastructure(ctx context.Context) error {
 	// Load existing state
@@ -57,46 +107,65 @@ func (m *TalisManager) PrepareInfrastructure(ctx context.Context) error {
 	}
 	m.state = state
 
+	userID, projectName, err := m.ensureProject(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Create instances
+	instanceIDs, err := m.createInstances(ctx, userID, projectName)
+	if err != nil {
+		return fmt.Errorf("failed to create instances: %w", err)
+	}
+
+	return m.provisionInstances(ctx, instanceIDs)
+}
+
+// ensureProject creates the user and the project unless they are recorded in
+// state already, and returns their IDs
+func (m *TalisManager) ensureProject(ctx context.Context) (uint, string, error) {
 	// Create user if not exists
-	userID := state.UserID
+	userID := m.state.UserID
 	if userID == 0 {
+		var err error
 		userID, err = m.createUserIfNotExists(ctx)
 		if err != nil {
-			return fmt.Errorf("failed to create user: %w", err)
+			return 0, "", fmt.Errorf("failed to create user: %w", err)
 		}
-		state.UserID = userID
-		if err := m.SaveState(state); err != nil {
-			return fmt.Errorf("failed to save state: %w", err)
+		m.state.UserID = userID
+		if err := m.SaveState(m.state); err != nil {
+			return 0, "", fmt.Errorf("failed to save state: %w", err)
 		}
 	}
 
 	// Create project if not exists
-	projectName := state.Projects[m.config.ProjectName]
+	projectName := m.state.Projects[m.config.ProjectName]
 	if projectName == "" {
+		var err error
 		projectName, err = m.createProjectIfNotExists(ctx, userID)
 		if err != nil {
-			return fmt.Errorf("failed to create project: %w", err)
+			return 0, "", fmt.Errorf("failed to create project: %w", err)
 		}
-		state.Projects[m.config.ProjectName] = projectName
-		if err := m.SaveState(state); err != nil {
-			return fmt.Errorf("failed to save state: %w", err)
+		m.state.Projects[m.config.ProjectName] = projectName
+		if err := m.SaveState(m.state); err != nil {
+			return 0, "", fmt.Errorf("failed to save state: %w", err)
 		}
 	}
 
-	// Create instances
-	instanceIDs, err := m.createInstances(ctx, userID, projectName)
-	if err != nil {
-		return fmt.Errorf("failed to create instances: %w", err)
-	}
+	return userID, projectName, nil
+}
 
+// provisionInstances waits for the instances to be ready and records their
+// public IPs in state
+func (m *TalisManager) provisionInstances(ctx context.Context, instanceIDs []uint) error {
 	// Wait for instances to be ready
-	if err := m.waitForInstancesToBeReady(ctx, instanceIDs, 15*time.Minute); err != nil {
+	if err := m.waitForInstancesToBeReady(ctx, instanceIDs, m.config.Timeouts.InstancesReady); err != nil {
 		return fmt.Errorf("failed to wait for instances: %w", err)
 	}
 
 	// Get IPs of instances
 	for _, instanceID := range instanceIDs {
-		instance, err := m.client.GetInstance(ctx, strconv.Itoa(int(instanceID)))
+		instance, err := m.provider.GetInstance(ctx, instanceID)
 		if err != nil {
 			return fmt.Errorf("failed to get instance %d: %w", instanceID, err)
 		}
@@ -106,6 +175,7 @@ func (m *TalisManager) PrepareInfrastructure(ctx context.Context) error {
 		for i, inst := range m.state.Instances[m.config.ProjectName] {
 			if inst.ID == instanceID {
 				m.state.Instances[m.config.ProjectName][i].PublicIP = instance.PublicIP
+				m.state.Instances[m.config.ProjectName][i].setStage(StageProvisioned)
 				break
 			}
 		}
@@ -128,6 +198,11 @@ func (m *TalisManager) InstallGoOnInstances(ctx context.Context) error {
 	}
 	m.state = state
 
+	// Check instances against configuration
+	if err := m.checkInstances(); err != nil {
+		return err
+	}
+
 	// Create a semaphore to limit concurrent installations
 	sem := make(chan struct{}, 10)
 	errChan := make(chan error, len(m.state.Instances[m.config.ProjectName]))
@@ -139,6 +214,10 @@ func (m *TalisManager) InstallGoOnInstances(ctx context.Context) error {
 			log.Printf("Skipping instance %d: no public IP", instance.ID)
 			continue
 		}
+		if instance.HasStage(StageGoInstalled) {
+			log.Printf("Go is already installed on instance %s (%s)", instance.Name, instance.PublicIP)
+			continue
+		}
 
 		wg.Add(1)
 		go func(inst InstanceInfo) {
@@ -159,27 +238,33 @@ else
     echo "Go is not installed"
     exit 1
 fi`
-			err := m.sshManager.ExecuteCommand(inst.PublicIP, checkCmd)
+			err := m.sshManager.ExecuteCommand(ctx, inst.PublicIP, checkCmd)
 			if err == nil {
 				log.Printf("Go is already installed on instance %s", inst.PublicIP)
+				if err := m.markStage(inst.Name, StageGoInstalled); err != nil {
+					errChan <- err
+				}
 				return
 			}
 
 			log.Printf("Installing Go on instance %s...", inst.PublicIP)
 
 			// Copy the installation script to the remote machine
-			if err := m.sshManager.CopyFile(inst.PublicIP, "scripts/install_go.sh", "install_go.sh"); err != nil {
+			if err := m.sshManager.CopyFile(ctx, inst.PublicIP, "scripts/install_go.sh", "install_go.sh"); err != nil {
 				errChan <- fmt.Errorf("failed to copy installation script to instance %s: %w", inst.PublicIP, err)
 				return
 			}
 
 			// Make the script executable and run it
-			if err := m.sshManager.ExecuteCommand(inst.PublicIP, fmt.Sprintf("chmod +x install_go.sh && ./install_go.sh %s", m.config.GoVersion)); err != nil {
+			if err := m.runStreaming(ctx, inst, fmt.Sprintf("chmod +x install_go.sh && ./install_go.sh %s", m.config.GoVersion)); err != nil {
 				errChan <- fmt.Errorf("failed to execute Go installation script on instance %s: %w", inst.PublicIP, err)
 				return
 			}
 
 			log.Printf("Successfully installed Go and required packages on instance %s", inst.PublicIP)
+			if err := m.markStage(inst.Name, StageGoInstalled); err != nil {
+				errChan <- err
+			}
 		}(instance)
 	}
 
@@ -206,26 +291,35 @@ func (m *TalisManager) InstallCelestiaAppOnInstances(ctx context.Context) error
 	}
 	m.state = state
 
+	// Check instances against configuration
+	if err := m.checkInstances(); err != nil {
+		return err
+	}
+
 	// Create a semaphore to limit concurrent installations
 	sem := make(chan struct{}, 10)
 	errChan := make(chan error, len(m.state.Instances[m.config.ProjectName]))
 	var wg sync.WaitGroup
 
 	// For each instance, check and install Celestia App if needed and if selected for this instance
-	for i, instance := range m.state.Instances[m.config.ProjectName] {
+	for _, instance := range m.state.Instances[m.config.ProjectName] {
 		if instance.PublicIP == "" {
 			log.Printf("Skipping instance %d: no public IP", instance.ID)
 			continue
 		}
 
 		// Skip instances where Celestia App installation is not requested
-		if i >= len(m.config.Instances) || !m.config.Instances[i].InstallCelestiaApp {
+		if !instance.Definition.InstallCelestiaApp {
 			log.Printf("Skipping Celestia App installation on instance %s (%s): not requested", instance.Name, instance.PublicIP)
 			continue
 		}
+		if instance.HasStage(StageAppInstalled) {
+			log.Printf("Celestia App is already installed on instance %s (%s)", instance.Name, instance.PublicIP)
+			continue
+		}
 
 		wg.Add(1)
-		go func(inst InstanceInfo, instDef config.InstanceDefinition) {
+		go func(inst InstanceInfo) {
 			defer wg.Done()
 
 			// Acquire semaphore
@@ -243,28 +337,34 @@ else
     echo "Celestia App is not installed"
     exit 1
 fi`
-			err := m.sshManager.ExecuteCommand(inst.PublicIP, checkCmd)
+			err := m.sshManager.ExecuteCommand(ctx, inst.PublicIP, checkCmd)
 			if err == nil {
 				log.Printf("Celestia App is already installed on instance %s (%s)", inst.Name, inst.PublicIP)
+				if err := m.markStage(inst.Name, StageAppInstalled); err != nil {
+					errChan <- err
+				}
 				return
 			}
 
 			log.Printf("Installing Celestia App on instance %s (%s)...", inst.Name, inst.PublicIP)
 
 			// Copy the installation script to the remote machine
-			if err := m.sshManager.CopyFile(inst.PublicIP, "scripts/install_celestia_app.sh", "install_celestia_app.sh"); err != nil {
+			if err := m.sshManager.CopyFile(ctx, inst.PublicIP, "scripts/install_celestia_app.sh", "install_celestia_app.sh"); err != nil {
 				errChan <- fmt.Errorf("failed to copy installation script to instance %s (%s): %w", inst.Name, inst.PublicIP, err)
 				return
 			}
 
 			// Make the script executable and run it
-			if err := m.sshManager.ExecuteCommand(inst.PublicIP, fmt.Sprintf("chmod +x install_celestia_app.sh && ./install_celestia_app.sh %s", m.config.CelestiaAppVersion)); err != nil {
+			if err := m.runStreaming(ctx, inst, fmt.Sprintf("chmod +x install_celestia_app.sh && ./install_celestia_app.sh %s", m.config.CelestiaAppVersion)); err != nil {
 				errChan <- fmt.Errorf("failed to execute Celestia App installation script on instance %s (%s): %w", inst.Name, inst.PublicIP, err)
 				return
 			}
 
 			log.Printf("Successfully installed Celestia App on instance %s (%s)", inst.Name, inst.PublicIP)
-		}(instance, m.config.Instances[i])
+			if err := m.markStage(inst.Name, StageAppInstalled); err != nil {
+				errChan <- err
+			}
+		}(instance)
 	}
 
 	// Wait for all goroutines to complete
@@ -290,26 +390,35 @@ func (m *TalisManager) InstallCelestiaNodeOnInstances(ctx context.Context) error
 	}
 	m.state = state
 
+	// Check instances against configuration
+	if err := m.checkInstances(); err != nil {
+		return err
+	}
+
 	// Create a semaphore to limit concurrent installations
 	sem := make(chan struct{}, 10)
 	errChan := make(chan error, len(m.state.Instances[m.config.ProjectName]))
 	var wg sync.WaitGroup
 
 	// For each instance, check and install Celestia Node if needed and if selected for this instance
-	for i, instance := range m.state.Instances[m.config.ProjectName] {
+	for _, instance := range m.state.Instances[m.config.ProjectName] {
 		if instance.PublicIP == "" {
 			log.Printf("Skipping instance %d: no public IP", instance.ID)
 			continue
 		}
 
 		// Skip instances where Celestia Node installation is not requested
-		if i >= len(m.config.Instances) || !m.config.Instances[i].InstallCelestiaNode {
+		if !instance.Definition.InstallCelestiaNode {
 			log.Printf("Skipping Celestia Node installation on instance %s (%s): not requested", instance.Name, instance.PublicIP)
 			continue
 		}
+		if instance.HasStage(StageNodeInstalled) {
+			log.Printf("Celestia Node is already installed on instance %s (%s)", instance.Name, instance.PublicIP)
+			continue
+		}
 
 		wg.Add(1)
-		go func(inst InstanceInfo, instDef config.InstanceDefinition) {
+		go func(inst InstanceInfo) {
 			defer wg.Done()
 
 			// Acquire semaphore
@@ -327,28 +436,34 @@ else
     echo "Celestia Node is not installed"
     exit 1
 fi`
-			err := m.sshManager.ExecuteCommand(inst.PublicIP, checkCmd)
+			err := m.sshManager.ExecuteCommand(ctx, inst.PublicIP, checkCmd)
 			if err == nil {
 				log.Printf("Celestia Node is already installed on instance %s (%s)", inst.Name, inst.PublicIP)
+				if err := m.markStage(inst.Name, StageNodeInstalled); err != nil {
+					errChan <- err
+				}
 				return
 			}
 
 			log.Printf("Installing Celestia Node on instance %s (%s)...", inst.Name, inst.PublicIP)
 
 			// Copy the installation script to the remote machine
-			if err := m.sshManager.CopyFile(inst.PublicIP, "scripts/install_celestia_node.sh", "install_celestia_node.sh"); err != nil {
+			if err := m.sshManager.CopyFile(ctx, inst.PublicIP, "scripts/install_celestia_node.sh", "install_celestia_node.sh"); err != nil {
 				errChan <- fmt.Errorf("failed to copy installation script to instance %s (%s): %w", inst.Name, inst.PublicIP, err)
 				return
 			}
 
 			// Make the script executable and run it
-			if err := m.sshManager.ExecuteCommand(inst.PublicIP, fmt.Sprintf("chmod +x install_celestia_node.sh && ./install_celestia_node.sh %s", m.config.CelestiaNodeVersion)); err != nil {
+			if err := m.runStreaming(ctx, inst, fmt.Sprintf("chmod +x install_celestia_node.sh && ./install_celestia_node.sh %s", m.config.CelestiaNodeVersion)); err != nil {
 				errChan <- fmt.Errorf("failed to execute Celestia Node installation script on instance %s (%s): %w", inst.Name, inst.PublicIP, err)
 				return
 			}
 
 			log.Printf("Successfully installed Celestia Node on instance %s (%s)", inst.Name, inst.PublicIP)
-		}(instance, m.config.Instances[i])
+			if err := m.markStage(inst.Name, StageNodeInstalled); err != nil {
+				errChan <- err
+			}
+		}(instance)
 	}
 
 	// Wait for all goroutines to complete
@@ -392,223 +507,242 @@ func (m *TalisManager) Run(ctx context.Context) error {
 
 // createUserIfNotExists creates a user if it doesn't exist
 func (m *TalisManager) createUserIfNotExists(ctx context.Context) (uint, error) {
-	users, err := m.client.GetUsers(ctx, handlers.UserGetParams{
-		Username: m.config.Username,
-	})
+	userID, err := m.provider.GetUser(ctx, m.config.Username)
 	if err != nil {
-		// Check if the error contains a 404 status code
-		if strings.Contains(err.Error(), "\"code\":404") {
-			user, err := m.client.CreateUser(ctx, handlers.CreateUserParams{
-				Username: m.config.Username,
-			})
+		if errors.Is(err, ErrNotFound) {
+			userID, err := m.provider.CreateUser(ctx, m.config.Username)
 			if err != nil {
 				return 0, fmt.Errorf("failed to create user: %w", err)
 			}
-			return user.UserID, nil
+			return userID, nil
 		}
 		return 0, fmt.Errorf("failed to get users: %w", err)
 	}
 
-	return users.User.ID, nil
+	return userID, nil
 }
 
 // createProjectIfNotExists creates a project if it doesn't exist
 func (m *TalisManager) createProjectIfNotExists(ctx context.Context, userID uint) (string, error) {
-	project, err := m.client.GetProject(ctx, handlers.ProjectGetParams{
-		Name:    m.config.ProjectName,
-		OwnerID: userID,
-	})
+	projectName, err := m.provider.GetProject(ctx, m.config.ProjectName, userID)
 	if err != nil {
-		// Check if the error contains a 404 status code
-		if strings.Contains(err.Error(), "\"code\":404") {
-			project, err := m.client.CreateProject(ctx, handlers.ProjectCreateParams{
-				Name:        m.config.ProjectName,
-				Description: m.config.ProjectDescription,
-				OwnerID:     userID,
-			})
+		if errors.Is(err, ErrNotFound) {
+			projectName, err := m.provider.CreateProject(ctx, m.config.ProjectName, m.config.ProjectDescription, userID)
 			if err != nil {
 				return "", fmt.Errorf("failed to create project: %w", err)
 			}
-			return project.Name, nil
+			return projectName, nil
 		}
 		return "", fmt.Errorf("failed to get project: %w", err)
 	}
 
-	return project.Name, nil
+	return projectName, nil
 }
 
 // createInstances creates the specified number of instances
 func (m *TalisManager) createInstances(ctx context.Context, userID uint, projectName string) ([]uint, error) {
-	// Check if instances already exist in state
-	if len(m.state.Instances[projectName]) > 0 {
-		// Return existing instance IDs
-		var instanceIDs []uint
-		for _, instance := range m.state.Instances[projectName] {
-			instanceIDs = append(instanceIDs, instance.ID)
+	// Instances in state are matched to their definitions by name. Only
+	// configured instances that were not created yet are created.
+	missing := make(map[string]bool)
+	var mismatches []InstanceMismatch
+	for _, mismatch := range m.matchInstances() {
+		if mismatch.Kind == MismatchMissing {
+			missing[mismatch.Name] = true
+			continue
 		}
-		return instanceIDs, nil
+		mismatches = append(mismatches, mismatch)
+	}
+	if len(mismatches) > 0 {
+		return nil, &InstanceMismatchError{Mismatches: mismatches}
 	}
 
-	// Create instances
+	// Existing instances are waited for along with the new ones
 	var instanceIDs []uint
-	for i, instanceDef := range m.config.Instances {
-		log.Printf("Creating instance %d: %s...", i, instanceDef.Name)
-		instanceID, err := m.createInstance(ctx, userID, projectName, i, instanceDef)
+	for _, instance := range m.state.Instances[projectName] {
+		instanceIDs = append(instanceIDs, instance.ID)
+	}
+
+	// Instances that exist in the project but not in state, e.g. because a
+	// previous run was interrupted, are adopted by apply instead of duplicated
+	var instanceDefs []config.InstanceDefinition
+	if len(missing) > 0 {
+		remotes, err := m.provider.ListInstances(ctx, projectName, userID)
 		if err != nil {
-			return nil, fmt.Errorf("failed to create instance %d: %w", i, err)
+			return nil, fmt.Errorf("failed to list project instances: %w", err)
+		}
+		for _, remote := range remotes {
+			if missing[remote.Name] && remote.Status != models.InstanceStatusTerminated {
+				return nil, fmt.Errorf("instance %s exists in project %s but not in state, run plan and apply to adopt it", remote.Name, projectName)
+			}
 		}
-		instanceIDs = append(instanceIDs, instanceID)
-
-		// Add instance to state (without installation preferences)
-		m.state.Instances[projectName] = append(m.state.Instances[projectName], InstanceInfo{
-			ID:       instanceID,
-			Name:     instanceDef.Name + "-0",
-			PublicIP: "",
-		})
 	}
 
-	// Save state
-	if err := m.SaveState(m.state); err != nil {
-		return nil, fmt.Errorf("failed to save state: %w", err)
+	// Create instances
+	for _, instanceDef := range m.config.Instances {
+		if missing[instanceName(instanceDef)] {
+			instanceDefs = append(instanceDefs, instanceDef)
+		}
+	}
+	createdIDs, err := m.createInstanceBatch(ctx, userID, projectName, instanceDefs)
+	if err != nil {
+		return nil, err
 	}
 
-	return instanceIDs, nil
+	return append(instanceIDs, createdIDs...), nil
 }
 
-// createInstance creates a single instance
-func (m *TalisManager) createInstance(ctx context.Context, userID uint, projectName string, instanceIndex int, instanceDef config.InstanceDefinition) (uint, error) {
-	err := m.client.CreateInstance(ctx, []types.InstanceRequest{
-		{
-			Name:              instanceDef.Name,
-			OwnerID:           userID,
-			ProjectName:       projectName,
-			Provider:          instanceDef.InstanceConfig.Provider,
-			NumberOfInstances: 1, // Only tested with 1
-			Provision:         false,
-			Region:            instanceDef.InstanceConfig.Region,
-			Size:              instanceDef.InstanceConfig.Size,
-			Image:             instanceDef.InstanceConfig.Image,
-			Tags:              instanceDef.InstanceConfig.Tags,
-			SSHKeyName:        instanceDef.InstanceConfig.SSHKeyName,
-			SSHKeyPath:        instanceDef.InstanceConfig.SSHKeyPath,
-			Volumes: []types.VolumeConfig{
-				{
-					Name:       instanceDef.InstanceConfig.VolumeConfig.Name,
-					SizeGB:     instanceDef.InstanceConfig.VolumeConfig.SizeGB,
-					MountPoint: instanceDef.InstanceConfig.VolumeConfig.MountPoint,
-				},
-			},
-		},
+// addInstance records a created instance in state along with the definition
+// it was created from and saves the state
+func (m *TalisManager) addInstance(instanceDef config.InstanceDefinition, instanceID uint, createdAt time.Time) error {
+	m.state.Instances[m.config.ProjectName] = append(m.state.Instances[m.config.ProjectName], InstanceInfo{
+		ID:         instanceID,
+		Name:       instanceName(instanceDef),
+		Role:       instanceDef.Role,
+		Region:     instanceDef.InstanceConfig.Region,
+		Size:       instanceDef.InstanceConfig.Size,
+		Definition: instanceDef,
+		CreatedAt:  createdAt.UTC(),
 	})
-	if err != nil {
-		return 0, fmt.Errorf("failed to create instance: %w", err)
-	}
 
-	pendingInstances, err := m.getPendingInstances(ctx, userID, projectName)
-	if err != nil {
-		return 0, fmt.Errorf("failed to get pending instances: %w", err)
+	if err := m.SaveState(m.state); err != nil {
+		return fmt.Errorf("failed to save state: %w", err)
 	}
+	return nil
+}
 
-	if len(pendingInstances) == 0 {
-		return 0, fmt.Errorf("no pending instances found")
+// createInstanceBatch creates the instances of the definitions with a single
+// request and records them in state. Talis does not return the IDs of the
+// instances it creates, so every definition is matched to the one instance
+// with its name that was not in the project before the request.
+func (m *TalisManager) createInstanceBatch(ctx context.Context, userID uint, projectName string, instanceDefs []config.InstanceDefinition) ([]uint, error) {
+	if len(instanceDefs) == 0 {
+		return nil, nil
 	}
 
-	// Return the most recently created instance
-	mostRecent := pendingInstances[0]
-	for _, instance := range pendingInstances {
-		if instance.CreatedAt.After(mostRecent.CreatedAt) {
-			mostRecent = instance
-		}
+	// Deleted instances are terminated asynchronously, so the instances being
+	// replaced may still be listed under the same names
+	before, err := m.provider.ListInstances(ctx, projectName, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list project instances: %w", err)
+	}
+	existing := make(map[uint]bool, len(before))
+	for _, instance := range before {
+		existing[instance.ID] = true
 	}
 
-	return mostRecent.ID, nil
-}
+	requests := make([]types.InstanceRequest, 0, len(instanceDefs))
+	for _, instanceDef := range instanceDefs {
+		log.Printf("Creating instance %s...", instanceName(instanceDef))
+		requests = append(requests, instanceRequest(instanceDef, userID, projectName))
+	}
+	if err := m.provider.CreateInstances(ctx, requests); err != nil {
+		return nil, fmt.Errorf("failed to create instances: %w", err)
+	}
 
-// getPendingInstances retrieves all pending instances
-func (m *TalisManager) getPendingInstances(ctx context.Context, userID uint, projectName string) ([]models.Instance, error) {
-	instances, err := m.client.ListProjectInstances(ctx, handlers.ProjectListInstancesParams{
-		Name:    projectName,
-		OwnerID: userID,
-	})
+	after, err := m.provider.ListInstances(ctx, projectName, userID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list project instances: %w", err)
 	}
-
-	pendingInstances := make([]models.Instance, 0)
-	for _, instance := range instances {
-		if instance.Status == models.InstanceStatusPending || instance.Status == models.InstanceStatusProvisioning {
-			pendingInstances = append(pendingInstances, instance)
+	created := make(map[string][]uint)
+	for _, instance := range after {
+		if !existing[instance.ID] {
+			created[instance.Name] = append(created[instance.Name], instance.ID)
 		}
 	}
 
-	return pendingInstances, nil
-}
-
-// waitForInstancesToBeReady waits for all instances to be ready
-func (m *TalisManager) waitForInstancesToBeReady(ctx context.Context, instanceIDs []uint, timeout time.Duration) error {
-	startTime := time.Now()
-	for {
-		allReady := true
-		for _, instanceID := range instanceIDs {
-			instance, err := m.client.GetInstance(ctx, strconv.Itoa(int(instanceID)))
-			if err != nil {
-				return fmt.Errorf("failed to get instance %d: %w", instanceID, err)
-			}
-
-			log.Printf("Instance %d status: %s", instanceID, instance.Status)
-			if instance.Status != models.InstanceStatusReady {
-				allReady = false
-				break
-			}
+	// Every instance that can be matched is recorded, even if others cannot,
+	// so that destroy finds all instances that were created
+	instanceIDs := make([]uint, 0, len(instanceDefs))
+	var unmatched []string
+	for _, instanceDef := range instanceDefs {
+		name := instanceName(instanceDef)
+		if len(created[name]) != 1 {
+			unmatched = append(unmatched, fmt.Sprintf("%s (%d new instances)", name, len(created[name])))
+			continue
 		}
 
-		if allReady {
-			log.Println("All instances are ready!")
-			return nil
+		// Verify the ID before it is written to state
+		instance, err := m.provider.GetInstance(ctx, created[name][0])
+		if err != nil {
+			unmatched = append(unmatched, fmt.Sprintf("%s (failed to get instance %d: %v)", name, created[name][0], err))
+			continue
 		}
+		if instance.Name != name {
+			unmatched = append(unmatched, fmt.Sprintf("%s (instance %d is named %s)", name, instance.ID, instance.Name))
+			continue
+		}
+		log.Printf("Created instance %s with ID %d", name, instance.ID)
 
-		if time.Since(startTime) > timeout {
-			return fmt.Errorf("instances not ready after %v", timeout)
+		// Save state after every instance so that an interrupted run does not
+		// lose track of the instances it already recorded
+		if err := m.addInstance(instanceDef, instance.ID, instance.CreatedAt); err != nil {
+			return instanceIDs, err
 		}
+		instanceIDs = append(instanceIDs, instance.ID)
+	}
 
-		time.Sleep(5 * time.Second)
+	if len(unmatched) > 0 {
+		return instanceIDs, fmt.Errorf("failed to match created instances in project %s, run plan and apply to reconcile them: %s", projectName, strings.Join(unmatched, ", "))
 	}
+	return instanceIDs, nil
 }
 
-// deleteInstances deletes all specified instances
+// instanceRequest returns the Talis request that creates the instance of a definition
+func instanceRequest(instanceDef config.InstanceDefinition, userID uint, projectName string) types.InstanceRequest {
+	return types.InstanceRequest{
+		Name:              instanceDef.Name,
+		OwnerID:           userID,
+		ProjectName:       projectName,
+		Provider:          instanceDef.InstanceConfig.Provider,
+		NumberOfInstances: 1, // Only tested with 1
+		Provision:         false,
+		Region:            instanceDef.InstanceConfig.Region,
+		Size:              instanceDef.InstanceConfig.Size,
+		Image:             instanceDef.InstanceConfig.Image,
+		Tags:              instanceDef.InstanceConfig.Tags,
+		SSHKeyName:        instanceDef.InstanceConfig.SSHKeyName,
+		SSHKeyPath:        instanceDef.InstanceConfig.SSHKeyPath,
+		Volumes: []types.VolumeConfig{
+			{
+				Name:       instanceDef.InstanceConfig.VolumeConfig.Name,
+				SizeGB:     instanceDef.InstanceConfig.VolumeConfig.SizeGB,
+				MountPoint: instanceDef.InstanceConfig.VolumeConfig.MountPoint,
+			},
+		},
+	}
+}
+
+// deleteInstances deletes all specified instances. Every instance leaves the
+// state as soon as Talis deleted it, so a failed or interrupted run keeps only
+// the instances that still exist.
 func (m *TalisManager) deleteInstances(ctx context.Context, userID uint, projectName string, instanceIDs []uint) error {
-	projectInstances := m.state.Instances[projectName]
-	remainingInstances := make([]InstanceInfo, 0, len(projectInstances))
-
-	for _, instance := range projectInstances {
-		shouldDelete := false
-		for _, id := range instanceIDs {
-			if instance.ID == id {
-				shouldDelete = true
-				break
-			}
+	for _, instance := range slices.Clone(m.state.Instances[projectName]) {
+		if !slices.Contains(instanceIDs, instance.ID) {
+			continue
 		}
 
-		fmt.Println("Instance", instance.Name, "should be deleted:", shouldDelete)
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		log.Printf("Deleting instance %s...", instance.Name)
+		err := m.provider.DeleteInstances(ctx, userID, projectName, []string{instance.Name})
+		if err != nil {
+			return fmt.Errorf("failed to delete instance %d: %w", instance.ID, err)
+		}
 
-		if shouldDelete {
-			err := m.client.DeleteInstances(ctx, types.DeleteInstancesRequest{
-				OwnerID:       userID,
-				ProjectName:   projectName,
-				InstanceNames: []string{instance.Name},
-			})
-			if err != nil {
-				return fmt.Errorf("failed to delete instance %d: %w", instance.ID, err)
-			}
-		} else {
-			remainingInstances = append(remainingInstances, instance)
+		m.state.Instances[projectName] = slices.DeleteFunc(m.state.Instances[projectName], func(info InstanceInfo) bool {
+			return info.ID == instance.ID
+		})
+		if err := m.SaveState(m.state); err != nil {
+			return fmt.Errorf("failed to save state: %w", err)
 		}
-	}
 
-	// Update state with remaining instances
-	m.state.Instances[projectName] = remainingInstances
-	if err := m.SaveState(m.state); err != nil {
-		return fmt.Errorf("failed to save state: %w", err)
+		// The IP may be handed out to another machine
+		if instance.PublicIP != "" {
+			if err := m.sshManager.Forget(instance.PublicIP); err != nil {
+				return fmt.Errorf("failed to forget host key of instance %s: %w", instance.Name, err)
+			}
+		}
 	}
 
 	return nil
@@ -671,8 +805,15 @@ func (m *TalisManager) DeleteAllInstances(ctx context.Context) error {
 	return nil
 }
 
-// SetupCelestiaNetwork sets up a Celestia network on the instances
-func (m *TalisManager) SetupCelestiaNetwork(ctx context.Context, chainID string) error {
+// SetupCelestiaNetwork sets up a Celestia network on the instances. Validators
+// become genesis validators, full nodes receive the genesis and peers only.
+// The node keys of earlier runs are reused unless rotateKeys is set.
+func (m *TalisManager) SetupCelestiaNetwork(ctx context.Context, chainID string, rotateKeys bool) error {
+	// Seeded keys would be regenerated as they were
+	if rotateKeys && m.config.KeySeed != nil {
+		return errors.New("keys derived from keys.seed cannot be rotated, change the seed instead")
+	}
+
 	// Load state
 	state, err := m.LoadState()
 	if err != nil {
@@ -680,20 +821,97 @@ func (m *TalisManager) SetupCelestiaNetwork(ctx context.Context, chainID string)
 	}
 	m.state = state
 
+	// Check instances against configuration
+	if err := m.checkInstances(); err != nil {
+		return err
+	}
+
 	// Create Celestia network
 	network := NewCelestiaNetwork(chainID, m.sshManager)
+	if err := network.ApplyGenesisParams(m.config.Genesis); err != nil {
+		return fmt.Errorf("failed to apply genesis params: %w", err)
+	}
+
+	// Fund the configured accounts
+	accounts, accountKeyring, err := m.ensureAccounts(network.genesis.EncodingConfig().Codec)
+	if err != nil {
+		return fmt.Errorf("failed to create accounts: %w", err)
+	}
+	if err := network.AddAccounts(accounts); err != nil {
+		return err
+	}
+
+	// Split the stake among the validators
+	var validatorNames []string
+	for _, instance := range m.state.Instances[m.config.ProjectName] {
+		if instance.Role == config.ValidatorNode {
+			validatorNames = append(validatorNames, instance.Name)
+		}
+	}
+	tokens, err := m.genesisTokens(validatorNames)
+	if err != nil {
+		return err
+	}
 
-	// Create genesis nodes for each instance
-	for i, instance := range m.state.Instances[m.config.ProjectName] {
+	// Create genesis nodes for each consensus instance
+	homeDir := "/root/.celestia-app"
+	validatorCount := 0
+	// Maps the instance names to the names of their nodes in the network
+	nodeNames := make(map[string]string)
+	// Maps the addresses of the node keys to their instances
+	keyOwners := make(map[string]string)
+	for _, instance := range m.state.Instances[m.config.ProjectName] {
+		if instance.Role != config.ValidatorNode && instance.Role != config.FullNode {
+			continue
+		}
 		if instance.PublicIP == "" {
 			return fmt.Errorf("instance %d has no public IP", instance.ID)
 		}
 
-		name := fmt.Sprintf("val%d", i)
-		homeDir := "/root/.celestia-app"
-		if err := network.CreateGenesisNode(ctx, name, homeDir, instance.PublicIP); err != nil {
+		signerKey, networkKey, err := m.nodeKeys(instance.Name, instance.Role == config.ValidatorNode, rotateKeys)
+		if err != nil {
+			return err
+		}
+		// Imported keys must not give two nodes the same identity
+		for _, key := range []*keyPair{signerKey, networkKey} {
+			if key == nil {
+				continue
+			}
+			address := key.PublicKey.Address().String()
+			if other, ok := keyOwners[address]; ok {
+				return fmt.Errorf("instances %s and %s have the same key %s", other, instance.Name, address)
+			}
+			keyOwners[address] = instance.Name
+		}
+
+		if instance.Role == config.FullNode {
+			if instance.Definition.StateSync {
+				network.WithSnapshotInterval(stateSyncSnapshotInterval)
+			}
+			if err := network.CreateFullNode(ctx, instance.Name, homeDir, instance.PublicIP, networkKey); err != nil {
+				return fmt.Errorf("failed to create full node %s: %w", instance.Name, err)
+			}
+			nodeNames[instance.Name] = instance.Name
+			continue
+		}
+
+		mnemonic, err := m.accountMnemonic(instance.Name)
+		if err != nil {
+			return err
+		}
+		name := fmt.Sprintf("val%d", validatorCount)
+		validatorCount++
+		if err := network.CreateGenesisNode(ctx, name, homeDir, instance.PublicIP, signerKey, networkKey, mnemonic, tokens[instance.Name]); err != nil {
 			return fmt.Errorf("failed to create genesis node %s: %w", name, err)
 		}
+		nodeNames[instance.Name] = name
+	}
+
+	if validatorCount == 0 {
+		return fmt.Errorf("no validator instances found for project %s", m.config.ProjectName)
+	}
+	if err := printVotingPower(validatorNames, nodeNames, tokens); err != nil {
+		return err
 	}
 
 	// Setup the network
@@ -701,10 +919,44 @@ func (m *TalisManager) SetupCelestiaNetwork(ctx context.Context, chainID string)
 		return fmt.Errorf("failed to setup network: %w", err)
 	}
 
+	// Keep the genesis file for nodes joining later
+	genesisPath, err := m.genesisPath()
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(genesisPath, network.GenesisJSON(), 0644); err != nil {
+		return fmt.Errorf("failed to save genesis file: %w", err)
+	}
+
+	if err := m.copyAccounts(ctx, accounts, accountKeyring); err != nil {
+		return fmt.Errorf("failed to copy accounts: %w", err)
+	}
+
+	// Record the public keys of the nodes
+	for instanceName, nodeName := range nodeNames {
+		node := network.Node(nodeName)
+		if node == nil {
+			return fmt.Errorf("node %s of instance %s not found in network", nodeName, instanceName)
+		}
+		err := m.updateInstance(instanceName, func(inst *InstanceInfo) {
+			inst.ValidatorPubKey = node.ValidatorPubKey()
+			inst.NetworkPubKey = node.NetworkPubKey()
+			if node.IsValidator() {
+				inst.KeyName = nodeName
+			}
+			inst.setStage(StageGenesis)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to record keys of instance %s: %w", instanceName, err)
+		}
+	}
+
 	return nil
 }
 
-// SetupCelestiaAppService sets up the systemd service for Celestia App validators
+// SetupCelestiaAppService sets up the systemd service for Celestia App on the
+// validators and full nodes. Full nodes that state sync are started last, once
+// the validators produce snapshots.
 func (m *TalisManager) SetupCelestiaAppService(ctx context.Context) error {
 	// Load state
 	state, err := m.LoadState()
@@ -713,24 +965,67 @@ func (m *TalisManager) SetupCelestiaAppService(ctx context.Context) error {
 	}
 	m.state = state
 
-	// Create a semaphore to limit concurrent operations
-	sem := make(chan struct{}, 10)
-	errChan := make(chan error, len(m.state.Instances[m.config.ProjectName]))
-	var wg sync.WaitGroup
+	// Check instances against configuration
+	if err := m.checkInstances(); err != nil {
+		return err
+	}
 
-	// For each instance, check and setup Celestia App service if needed
-	for i, instance := range m.state.Instances[m.config.ProjectName] {
+	var instances, stateSyncInstances []InstanceInfo
+	for _, instance := range m.state.Instances[m.config.ProjectName] {
 		if instance.PublicIP == "" {
 			log.Printf("Skipping instance %d: no public IP", instance.ID)
 			continue
 		}
 
 		// Skip instances where Celestia App installation is not requested
-		if i >= len(m.config.Instances) || !m.config.Instances[i].InstallCelestiaApp {
+		if !instance.Definition.InstallCelestiaApp {
 			log.Printf("Skipping Celestia App service setup on instance %s (%s): not requested", instance.Name, instance.PublicIP)
 			continue
 		}
 
+		if instance.Definition.StateSync {
+			stateSyncInstances = append(stateSyncInstances, instance)
+		} else {
+			instances = append(instances, instance)
+		}
+	}
+
+	if err := m.startCelestiaAppServices(ctx, instances); err != nil {
+		return err
+	}
+	if len(stateSyncInstances) == 0 {
+		return nil
+	}
+
+	var rpcServers []string
+	for _, validator := range m.instancesByRole(config.ValidatorNode) {
+		if validator.PublicIP != "" {
+			rpcServers = append(rpcServers, validator.PublicIP)
+		}
+	}
+	if len(rpcServers) == 0 {
+		return fmt.Errorf("no validators available as state sync RPC servers")
+	}
+
+	for _, instance := range stateSyncInstances {
+		if err := m.configureStateSync(ctx, instance, rpcServers); err != nil {
+			return fmt.Errorf("failed to configure state sync on instance %s (%s): %w", instance.Name, instance.PublicIP, err)
+		}
+	}
+
+	return m.startCelestiaAppServices(ctx, stateSyncInstances)
+}
+
+// startCelestiaAppServices sets up and starts the Celestia App systemd service
+// on the given instances
+func (m *TalisManager) startCelestiaAppServices(ctx context.Context, instances []InstanceInfo) error {
+	// Create a semaphore to limit concurrent operations
+	sem := make(chan struct{}, 10)
+	errChan := make(chan error, len(instances))
+	var wg sync.WaitGroup
+
+	// For each instance, setup Celestia App service
+	for _, instance := range instances {
 		wg.Add(1)
 		go func(inst InstanceInfo) {
 			defer wg.Done()
@@ -742,18 +1037,139 @@ func (m *TalisManager) SetupCelestiaAppService(ctx context.Context) error {
 			log.Printf("Setting up Celestia App service on instance %s (%s)...", inst.Name, inst.PublicIP)
 
 			// Copy the service setup script to the remote machine
-			if err := m.sshManager.CopyFile(inst.PublicIP, "scripts/setup_celestia_appd_service.sh", "setup_celestia_appd_service.sh"); err != nil {
+			if err := m.sshManager.CopyFile(ctx, inst.PublicIP, "scripts/setup_celestia_appd_service.sh", "setup_celestia_appd_service.sh"); err != nil {
 				errChan <- fmt.Errorf("failed to copy service setup script to instance %s (%s): %w", inst.Name, inst.PublicIP, err)
 				return
 			}
 
 			// Make the script executable and run it
-			if err := m.sshManager.ExecuteCommand(inst.PublicIP, "chmod +x setup_celestia_appd_service.sh && sudo ./setup_celestia_appd_service.sh"); err != nil {
+			if err := m.runStreaming(ctx, inst, "chmod +x setup_celestia_appd_service.sh && sudo ./setup_celestia_appd_service.sh"); err != nil {
 				errChan <- fmt.Errorf("failed to execute service setup script on instance %s (%s): %w", inst.Name, inst.PublicIP, err)
 				return
 			}
 
 			log.Printf("Successfully set up Celestia App service on instance %s (%s)", inst.Name, inst.PublicIP)
+			if err := m.markStage(inst.Name, StageStarted); err != nil {
+				errChan <- err
+			}
+		}(instance)
+	}
+
+	// Wait for all goroutines to complete
+	wg.Wait()
+	close(errChan)
+
+	// Check for any errors
+	for err := range errChan {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// configureStateSync enables state sync in the config.toml of a full node,
+// trusting the most recent snapshot height of the validators. If the chain has
+// not reached the first snapshot yet the node falls back to block sync.
+func (m *TalisManager) configureStateSync(ctx context.Context, inst InstanceInfo, rpcServers []string) error {
+	var status struct {
+		SyncInfo struct {
+			LatestBlockHeight int64 `json:"latest_block_height,string"`
+		} `json:"sync_info"`
+	}
+	if err := rpcGet(ctx, rpcServers[0], "status", &status); err != nil {
+		return err
+	}
+
+	latest := status.SyncInfo.LatestBlockHeight
+	if latest < stateSyncSnapshotInterval {
+		log.Printf("Chain is at height %d, below the first snapshot at %d: instance %s (%s) will block sync",
+			latest, stateSyncSnapshotInterval, inst.Name, inst.PublicIP)
+		return nil
+	}
+
+	trustHeight := latest - latest%stateSyncSnapshotInterval
+	trustHash, err := blockHash(ctx, rpcServers[0], trustHeight)
+	if err != nil {
+		return err
+	}
+
+	// CometBFT requires at least two RPC servers for light client verification
+	servers := make([]string, 0, len(rpcServers))
+	for _, ip := range rpcServers {
+		servers = append(servers, ip+":26657")
+	}
+	if len(servers) == 1 {
+		servers = append(servers, servers[0])
+	}
+
+	log.Printf("Configuring state sync on instance %s (%s) with trust height %d", inst.Name, inst.PublicIP, trustHeight)
+	cmd := fmt.Sprintf(`sed -i -e '/^\[statesync\]/,/^\[/{s|^enable = .*|enable = true|;s|^rpc_servers = .*|rpc_servers = "%s"|;s|^trust_height = .*|trust_height = %d|;s|^trust_hash = .*|trust_hash = "%s"|}' /root/.celestia-app/config/config.toml`,
+		strings.Join(servers, ","), trustHeight, trustHash)
+	return m.sshManager.ExecuteCommand(ctx, inst.PublicIP, cmd)
+}
+
+// StopCelestiaAppService stops the Celestia App service on all instances running it
+func (m *TalisManager) StopCelestiaAppService(ctx context.Context) error {
+	return m.stopService(ctx, "celestia-appd", func(inst InstanceInfo) bool {
+		return inst.Definition.InstallCelestiaApp
+	})
+}
+
+// StopBridgeNodes stops the bridge node service on all bridge instances
+func (m *TalisManager) StopBridgeNodes(ctx context.Context) error {
+	return m.stopService(ctx, "celestia-bridge", func(inst InstanceInfo) bool {
+		return inst.Role == config.BridgeNode
+	})
+}
+
+// stopService stops the given systemd service on the instances selected by the filter
+func (m *TalisManager) stopService(ctx context.Context, service string, selected func(InstanceInfo) bool) error {
+	// Load state
+	state, err := m.LoadState()
+	if err != nil {
+		return fmt.Errorf("failed to load state: %w", err)
+	}
+	m.state = state
+
+	// Create a semaphore to limit concurrent operations
+	sem := make(chan struct{}, 10)
+	errChan := make(chan error, len(m.state.Instances[m.config.ProjectName]))
+	var wg sync.WaitGroup
+
+	// For each instance, stop the service
+	for _, instance := range m.state.Instances[m.config.ProjectName] {
+		if instance.PublicIP == "" {
+			log.Printf("Skipping instance %d: no public IP", instance.ID)
+			continue
+		}
+
+		// Skip instances that do not run the service
+		if !selected(instance) {
+			continue
+		}
+
+		wg.Add(1)
+		go func(inst InstanceInfo) {
+			defer wg.Done()
+
+			// Acquire semaphore
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			log.Printf("Stopping %s service on instance %s (%s)...", service, inst.Name, inst.PublicIP)
+			if err := m.runStreaming(ctx, inst, "sudo systemctl stop "+service); err != nil {
+				errChan <- fmt.Errorf("failed to stop %s on instance %s (%s): %w", service, inst.Name, inst.PublicIP, err)
+				return
+			}
+			log.Printf("Stopped %s service on instance %s (%s)", service, inst.Name, inst.PublicIP)
+			err := m.updateInstance(inst.Name, func(inst *InstanceInfo) {
+				delete(inst.Stages, StageStarted)
+			})
+			if err != nil {
+				errChan <- err
+			}
 		}(instance)
 	}
 
@@ -770,3 +1186,35 @@ func (m *TalisManager) SetupCelestiaAppService(ctx context.Context) error {
 
 	return nil
 }
+
+// Instances returns the instances of the current project as recorded in state
+func (m *TalisManager) Instances() ([]InstanceInfo, error) {
+	state, err := m.LoadState()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load state: %w", err)
+	}
+	m.state = state
+
+	return state.Instances[m.config.ProjectName], nil
+}
+
+// TrustInstance replaces the recorded host key of the named instance with the
+// key it currently presents, e.g. after the instance was rebuilt
+func (m *TalisManager) TrustInstance(ctx context.Context, name string) error {
+	instances, err := m.Instances()
+	if err != nil {
+		return err
+	}
+
+	for _, instance := range instances {
+		if instance.Name != name {
+			continue
+		}
+		if instance.PublicIP == "" {
+			return fmt.Errorf("instance %s has no public IP", name)
+		}
+		return m.sshManager.Trust(ctx, instance.PublicIP)
+	}
+
+	return fmt.Errorf("instance %s not found", name)
+}
@@ -0,0 +1,293 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/celestiaorg/talis-test/config"
+)
+
+// newTestManager returns a manager of the given instances that keeps its
+// state in a temporary home directory, talks to the fake provider and treats
+// every host as reachable over SSH. Instances of the provider become ready on
+// their first poll so that tests do not wait between polls.
+func newTestManager(t *testing.T, provider *FakeProvider, names ...string) *TalisManager {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	provider.PollsPerTransition = 0
+
+	cfg := config.DefaultConfig()
+	cfg.Instances = nil
+	for _, name := range names {
+		cfg.Instances = append(cfg.Instances, config.NewInstanceDefinition(name, true, false).WithRole(config.ValidatorNode))
+	}
+	cfg.Timeouts.InstancesReady = 10 * time.Second
+
+	m, err := NewTalisManagerWithProvider(cfg, provider)
+	if err != nil {
+		t.Fatalf("failed to create manager: %v", err)
+	}
+	m.checkSSH = func(ctx context.Context, host string) error { return nil }
+	t.Cleanup(func() { m.Close() })
+	return m
+}
+
+// loadTestState loads the state saved by the manager
+func loadTestState(t *testing.T, m *TalisManager) State {
+	t.Helper()
+	state, err := m.LoadState()
+	if err != nil {
+		t.Fatalf("failed to load state: %v", err)
+	}
+	return state
+}
+
+func TestPrepareInfrastructure(t *testing.T) {
+	provider := NewFakeProvider()
+	m := newTestManager(t, provider, "validator-1", "validator-2")
+	ctx := context.Background()
+
+	if err := m.PrepareInfrastructure(ctx); err != nil {
+		t.Fatalf("PrepareInfrastructure: %v", err)
+	}
+
+	instances := loadTestState(t, m).Instances[m.config.ProjectName]
+	if len(instances) != 2 {
+		t.Fatalf("got %d instances in state, want 2", len(instances))
+	}
+	for i, want := range []string{"validator-1-0", "validator-2-0"} {
+		instance := instances[i]
+		if instance.Name != want {
+			t.Errorf("instance %d is named %s, want %s", i, instance.Name, want)
+		}
+		if instance.PublicIP == "" {
+			t.Errorf("instance %s has no public IP", instance.Name)
+		}
+		if !instance.HasStage(StageProvisioned) {
+			t.Errorf("instance %s is not provisioned", instance.Name)
+		}
+		if instance.Role != config.ValidatorNode {
+			t.Errorf("instance %s has role %s, want %s", instance.Name, instance.Role, config.ValidatorNode)
+		}
+	}
+
+	// A second run finds everything in place and creates nothing
+	if err := m.PrepareInfrastructure(ctx); err != nil {
+		t.Fatalf("second PrepareInfrastructure: %v", err)
+	}
+	if got := len(provider.Instances()); got != 2 {
+		t.Errorf("provider has %d instances after the second run, want 2", got)
+	}
+	if got := len(loadTestState(t, m).Instances[m.config.ProjectName]); got != 2 {
+		t.Errorf("state has %d instances after the second run, want 2", got)
+	}
+}
+
+func TestPrepareInfrastructureCreatesMissingInstances(t *testing.T) {
+	provider := NewFakeProvider()
+	m := newTestManager(t, provider, "validator-1")
+	ctx := context.Background()
+
+	if err := m.PrepareInfrastructure(ctx); err != nil {
+		t.Fatalf("PrepareInfrastructure: %v", err)
+	}
+	first := loadTestState(t, m).Instances[m.config.ProjectName][0]
+
+	m.config.Instances = append(m.config.Instances, config.NewInstanceDefinition("validator-2", true, false).WithRole(config.ValidatorNode))
+	if err := m.PrepareInfrastructure(ctx); err != nil {
+		t.Fatalf("PrepareInfrastructure: %v", err)
+	}
+
+	instances := loadTestState(t, m).Instances[m.config.ProjectName]
+	if len(instances) != 2 {
+		t.Fatalf("got %d instances in state, want 2", len(instances))
+	}
+	if instances[0].ID != first.ID {
+		t.Errorf("existing instance was recreated: ID %d, want %d", instances[0].ID, first.ID)
+	}
+	if instances[1].Name != "validator-2-0" || instances[1].PublicIP == "" {
+		t.Errorf("new instance = %+v, want validator-2-0 with a public IP", instances[1])
+	}
+}
+
+func TestPrepareInfrastructureProviderFailures(t *testing.T) {
+	for _, method := range []string{"GetUser", "CreateUser", "GetProject", "CreateProject", "ListInstances", "CreateInstances", "GetInstance"} {
+		t.Run(method, func(t *testing.T) {
+			provider := NewFakeProvider()
+			m := newTestManager(t, provider, "validator-1")
+			injected := errors.New("injected " + method + " failure")
+			provider.FailNext(method, injected)
+
+			err := m.PrepareInfrastructure(context.Background())
+			if !errors.Is(err, injected) {
+				t.Fatalf("error = %v, want %v", err, injected)
+			}
+		})
+	}
+}
+
+func TestPrepareInfrastructureCreateFailureRecordsNothing(t *testing.T) {
+	provider := NewFakeProvider()
+	m := newTestManager(t, provider, "validator-1", "validator-2")
+	provider.FailNext("CreateInstances", errors.New("quota exceeded"))
+
+	err := m.PrepareInfrastructure(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "quota exceeded") {
+		t.Fatalf("error = %v, want quota exceeded", err)
+	}
+	if got := len(provider.Instances()); got != 0 {
+		t.Errorf("provider has %d instances, want 0", got)
+	}
+	if got := len(loadTestState(t, m).Instances[m.config.ProjectName]); got != 0 {
+		t.Errorf("state has %d instances, want 0", got)
+	}
+
+	// The next run creates the instances
+	if err := m.PrepareInfrastructure(context.Background()); err != nil {
+		t.Fatalf("PrepareInfrastructure after failure: %v", err)
+	}
+	if got := len(loadTestState(t, m).Instances[m.config.ProjectName]); got != 2 {
+		t.Errorf("state has %d instances, want 2", got)
+	}
+}
+
+func TestPrepareInfrastructureRefusesUntrackedInstances(t *testing.T) {
+	provider := NewFakeProvider()
+	m := newTestManager(t, provider, "validator-1")
+	ctx := context.Background()
+
+	if err := m.PrepareInfrastructure(ctx); err != nil {
+		t.Fatalf("PrepareInfrastructure: %v", err)
+	}
+
+	// Lose track of the instance as an interrupted run would
+	state := loadTestState(t, m)
+	state.Instances[m.config.ProjectName] = nil
+	if err := m.SaveState(state); err != nil {
+		t.Fatalf("failed to save state: %v", err)
+	}
+
+	err := m.PrepareInfrastructure(ctx)
+	if err == nil || !strings.Contains(err.Error(), "exists in project") {
+		t.Fatalf("error = %v, want the instance to be reported as untracked", err)
+	}
+	if got := len(provider.Instances()); got != 1 {
+		t.Errorf("provider has %d instances, want 1", got)
+	}
+}
+
+func TestDeleteAllInstances(t *testing.T) {
+	provider := NewFakeProvider()
+	m := newTestManager(t, provider, "validator-1", "validator-2")
+	ctx := context.Background()
+
+	if err := m.PrepareInfrastructure(ctx); err != nil {
+		t.Fatalf("PrepareInfrastructure: %v", err)
+	}
+	if err := m.DeleteAllInstances(ctx); err != nil {
+		t.Fatalf("DeleteAllInstances: %v", err)
+	}
+
+	if got := len(provider.Instances()); got != 0 {
+		t.Errorf("provider has %d instances, want 0", got)
+	}
+	if got := len(loadTestState(t, m).Instances[m.config.ProjectName]); got != 0 {
+		t.Errorf("state has %d instances, want 0", got)
+	}
+
+	// Nothing left to delete
+	if err := m.DeleteAllInstances(ctx); err != nil {
+		t.Fatalf("second DeleteAllInstances: %v", err)
+	}
+}
+
+func TestDeleteAllInstancesFailure(t *testing.T) {
+	provider := NewFakeProvider()
+	m := newTestManager(t, provider, "validator-1", "validator-2")
+	ctx := context.Background()
+
+	if err := m.PrepareInfrastructure(ctx); err != nil {
+		t.Fatalf("PrepareInfrastructure: %v", err)
+	}
+
+	// The first instance is deleted, the second is not
+	injected := errors.New("injected DeleteInstances failure")
+	provider.FailNext("DeleteInstances", nil)
+	provider.FailNext("DeleteInstances", injected)
+
+	if err := m.DeleteAllInstances(ctx); !errors.Is(err, injected) {
+		t.Fatalf("error = %v, want %v", err, injected)
+	}
+	if got := provider.Instances(); len(got) != 1 || got[0].Name != "validator-2-0" {
+		t.Errorf("provider has instances %+v, want validator-2-0", got)
+	}
+	instances := loadTestState(t, m).Instances[m.config.ProjectName]
+	if len(instances) != 1 || instances[0].Name != "validator-2-0" {
+		t.Errorf("state has instances %+v, want validator-2-0", instances)
+	}
+
+	// A retry deletes the rest
+	if err := m.DeleteAllInstances(ctx); err != nil {
+		t.Fatalf("DeleteAllInstances retry: %v", err)
+	}
+	if got := len(provider.Instances()); got != 0 {
+		t.Errorf("provider has %d instances after the retry, want 0", got)
+	}
+	if got := len(loadTestState(t, m).Instances[m.config.ProjectName]); got != 0 {
+		t.Errorf("state has %d instances after the retry, want 0", got)
+	}
+}
+
+func TestDeleteAllInstancesWithoutProject(t *testing.T) {
+	m := newTestManager(t, NewFakeProvider(), "validator-1")
+
+	err := m.DeleteAllInstances(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("error = %v, want project not found", err)
+	}
+}
+
+func TestCreateInstanceBatchRecordsMatchedInstances(t *testing.T) {
+	provider := NewFakeProvider()
+	m := newTestManager(t, provider)
+	ctx := context.Background()
+
+	state, err := m.LoadState()
+	if err != nil {
+		t.Fatalf("failed to load state: %v", err)
+	}
+	m.state = state
+	userID, projectName, err := m.ensureProject(ctx)
+	if err != nil {
+		t.Fatalf("ensureProject: %v", err)
+	}
+
+	// The duplicated definition yields two new instances with the same name
+	// that cannot be told apart
+	validator := func(name string) config.InstanceDefinition {
+		return config.NewInstanceDefinition(name, true, false).WithRole(config.ValidatorNode)
+	}
+	defs := []config.InstanceDefinition{validator("validator-1"), validator("validator-2"), validator("validator-2"), validator("validator-3")}
+
+	ids, err := m.createInstanceBatch(ctx, userID, projectName, defs)
+	if err == nil {
+		t.Fatal("expected an error for the duplicated instance")
+	}
+	if !strings.Contains(err.Error(), "validator-2-0") || strings.Contains(err.Error(), "validator-1-0") || strings.Contains(err.Error(), "validator-3-0") {
+		t.Errorf("error = %v, want only validator-2-0 reported", err)
+	}
+	if len(ids) != 2 {
+		t.Errorf("got %d instance IDs, want 2", len(ids))
+	}
+
+	var names []string
+	for _, instance := range loadTestState(t, m).Instances[m.config.ProjectName] {
+		names = append(names, instance.Name)
+	}
+	if strings.Join(names, ",") != "validator-1-0,validator-3-0" {
+		t.Errorf("state has instances %v, want validator-1-0 and validator-3-0", names)
+	}
+}
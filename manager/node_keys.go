@@ -0,0 +1,238 @@
+package manager
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/celestiaorg/talis-test/config"
+	tmjson "github.com/tendermint/tendermint/libs/json"
+	"github.com/tendermint/tendermint/p2p"
+	"github.com/tendermint/tendermint/privval"
+	coretypes "github.com/tendermint/tendermint/types"
+)
+
+const (
+	// signerKeyFile is the file the consensus key of a node is stored in
+	signerKeyFile = "priv_validator_key.json"
+	// networkKeyFile is the file the P2P key of a node is stored in
+	networkKeyFile = "node_key.json"
+)
+
+// KeysDir returns the directory holding the node keys of an environment, with
+// a subdirectory per instance
+func KeysDir(environment string) (string, error) {
+	dir, err := EnvironmentDir(environment)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "keys"), nil
+}
+
+// nodeKeys returns the keys of an instance. Keys imported by the instance
+// definition come first. Otherwise keys stored by an earlier genesis or
+// scale-out are reused, so the identities of the nodes stay the same.
+// Missing keys are generated, from the configured key seed if there is one,
+// and stored. Full nodes have no signer key. With rotate, the stored keys are
+// replaced by new ones, which callers only ask for without a key seed.
+func (m *TalisManager) nodeKeys(instanceName string, validator, rotate bool) (signerKey, networkKey *keyPair, err error) {
+	imported := m.importedKeys(instanceName)
+
+	keysDir, err := KeysDir(m.config.Environment)
+	if err != nil {
+		return nil, nil, err
+	}
+	dir := filepath.Join(keysDir, instanceName)
+	if rotate {
+		if err := os.RemoveAll(dir); err != nil {
+			return nil, nil, fmt.Errorf("failed to remove keys of instance %s: %w", instanceName, err)
+		}
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, nil, err
+	}
+
+	// Every key has its own seeded generator, so a key does not depend on
+	// which other keys of the instance are generated
+	signerKeygen, networkKeygen := newRandomKeyGenerator(), newRandomKeyGenerator()
+	if m.config.KeySeed != nil {
+		signerKeygen = newSeededKeyGenerator(*m.config.KeySeed, instanceName+"/signer")
+		networkKeygen = newSeededKeyGenerator(*m.config.KeySeed, instanceName+"/node")
+	}
+
+	if validator && imported.ValidatorKey != "" {
+		signerKey, err = importKeyFile(imported.ValidatorKey, loadSignerKey)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to import validator key of instance %s: %w", instanceName, err)
+		}
+		if err := m.checkSignerKeyType(signerKey); err != nil {
+			return nil, nil, fmt.Errorf("failed to import validator key of instance %s: %w", instanceName, err)
+		}
+		if err := saveSignerKey(filepath.Join(dir, signerKeyFile), signerKey); err != nil {
+			return nil, nil, fmt.Errorf("failed to save signer key of instance %s: %w", instanceName, err)
+		}
+	} else if validator {
+		signerKey, err = loadSignerKey(filepath.Join(dir, signerKeyFile))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to load signer key of instance %s: %w", instanceName, err)
+		}
+		if signerKey == nil {
+			signerKey = signerKeygen.Generate(m.signerKeyType())
+			if err := saveSignerKey(filepath.Join(dir, signerKeyFile), signerKey); err != nil {
+				return nil, nil, fmt.Errorf("failed to save signer key of instance %s: %w", instanceName, err)
+			}
+		}
+		// The key types of the chain may have changed since the key was stored
+		if err := m.checkSignerKeyType(signerKey); err != nil {
+			return nil, nil, fmt.Errorf("signer key of instance %s: %w, replace it with genesis --rotate-keys", instanceName, err)
+		}
+	}
+
+	if imported.NodeKey != "" {
+		networkKey, err = importKeyFile(imported.NodeKey, loadNetworkKey)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to import node key of instance %s: %w", instanceName, err)
+		}
+		if err := saveNetworkKey(filepath.Join(dir, networkKeyFile), networkKey); err != nil {
+			return nil, nil, fmt.Errorf("failed to save network key of instance %s: %w", instanceName, err)
+		}
+	} else {
+		networkKey, err = loadNetworkKey(filepath.Join(dir, networkKeyFile))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to load network key of instance %s: %w", instanceName, err)
+		}
+		if networkKey == nil {
+			networkKey = networkKeygen.Generate(ed25519Type)
+			if err := saveNetworkKey(filepath.Join(dir, networkKeyFile), networkKey); err != nil {
+				return nil, nil, fmt.Errorf("failed to save network key of instance %s: %w", instanceName, err)
+			}
+		}
+	}
+
+	return signerKey, networkKey, nil
+}
+
+// importedKeys returns the existing keys the configured definition of an
+// instance points at
+func (m *TalisManager) importedKeys(name string) config.ImportedKeys {
+	def, _ := m.configuredDefinition(name)
+	return def.Keys
+}
+
+// accountMnemonic returns the imported mnemonic of the account of a validator
+// instance, or an empty string if it has none. The mnemonic is validated when
+// it is imported into a keyring.
+func (m *TalisManager) accountMnemonic(name string) (string, error) {
+	path := m.importedKeys(name).Mnemonic
+	if path == "" {
+		return "", nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read mnemonic of instance %s: %w", name, err)
+	}
+	return strings.Join(strings.Fields(string(data)), " "), nil
+}
+
+// checkSignerKeyType checks that the chain accepts the type of a consensus key
+func (m *TalisManager) checkSignerKeyType(key *keyPair) error {
+	keyTypes := m.config.Genesis.ValidatorPubKeyTypes
+	if len(keyTypes) == 0 {
+		keyTypes = []string{coretypes.ABCIPubKeyTypeEd25519}
+	}
+	if !slices.Contains(keyTypes, key.PublicKey.Type()) {
+		return fmt.Errorf("key type %s is not one of the validator key types %s", key.PublicKey.Type(), strings.Join(keyTypes, ", "))
+	}
+	return nil
+}
+
+// signerKeyType returns the type of generated consensus keys, the first key
+// type the chain accepts
+func (m *TalisManager) signerKeyType() keyType {
+	keyTypes := m.config.Genesis.ValidatorPubKeyTypes
+	if len(keyTypes) > 0 && keyTypes[0] == coretypes.ABCIPubKeyTypeSecp256k1 {
+		return secp256k1Type
+	}
+	return ed25519Type
+}
+
+// importKeyFile loads a key file that must exist
+func importKeyFile(path string, load func(string) (*keyPair, error)) (*keyPair, error) {
+	key, err := load(path)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, fmt.Errorf("%s does not exist", path)
+	}
+	return key, nil
+}
+
+// loadSignerKey reads a priv_validator_key.json file. It returns nil if the
+// file does not exist.
+func loadSignerKey(path string) (*keyPair, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var key privval.FilePVKey
+	if err := tmjson.Unmarshal(data, &key); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if key.PrivKey == nil {
+		return nil, fmt.Errorf("%s has no private key", path)
+	}
+	pubKey := key.PrivKey.PubKey()
+	if key.PubKey != nil && !key.PubKey.Equals(pubKey) {
+		return nil, fmt.Errorf("public key in %s does not match its private key", path)
+	}
+	if len(key.Address) > 0 && !bytes.Equal(key.Address, pubKey.Address()) {
+		return nil, fmt.Errorf("address in %s does not match its private key", path)
+	}
+
+	return &keyPair{PrivateKey: key.PrivKey, PublicKey: pubKey}, nil
+}
+
+// saveSignerKey writes a key in the priv_validator_key.json format
+func saveSignerKey(path string, key *keyPair) error {
+	data, err := tmjson.MarshalIndent(privval.FilePVKey{
+		Address: key.PublicKey.Address(),
+		PubKey:  key.PublicKey,
+		PrivKey: key.PrivateKey,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data, 0600)
+}
+
+// loadNetworkKey reads a node_key.json file. It returns nil if the file does
+// not exist.
+func loadNetworkKey(path string) (*keyPair, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	nodeKey, err := p2p.LoadNodeKey(path)
+	if err != nil {
+		return nil, err
+	}
+	if nodeKey.PrivKey == nil {
+		return nil, fmt.Errorf("%s has no private key", path)
+	}
+
+	return &keyPair{PrivateKey: nodeKey.PrivKey, PublicKey: nodeKey.PubKey()}, nil
+}
+
+// saveNetworkKey writes a key in the node_key.json format
+func saveNetworkKey(path string, key *keyPair) error {
+	nodeKey := &p2p.NodeKey{PrivKey: key.PrivateKey}
+	return nodeKey.SaveAs(path)
+}
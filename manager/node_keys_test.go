@@ -0,0 +1,137 @@
+package manager
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	coretypes "github.com/tendermint/tendermint/types"
+)
+
+func TestNodeKeysSeeded(t *testing.T) {
+	m := newTestManager(t, NewFakeProvider())
+	seed := int64(42)
+	m.config.KeySeed = &seed
+
+	signerKey, networkKey, err := m.nodeKeys("validator-1-0", true, false)
+	if err != nil {
+		t.Fatalf("nodeKeys: %v", err)
+	}
+	if signerKey.PublicKey.Equals(networkKey.PublicKey) {
+		t.Error("signer and network key are the same")
+	}
+
+	// The node ID does not depend on the role of the instance
+	_, fullNodeKey, err := m.nodeKeys("validator-1-0", false, true)
+	if err != nil {
+		t.Fatalf("nodeKeys: %v", err)
+	}
+	if !fullNodeKey.PublicKey.Equals(networkKey.PublicKey) {
+		t.Error("network key changed with the role of the instance")
+	}
+
+	// A lost network key is regenerated as it was, not from the signer key
+	keysDir, err := KeysDir(m.config.Environment)
+	if err != nil {
+		t.Fatalf("KeysDir: %v", err)
+	}
+	if err := os.Remove(filepath.Join(keysDir, "validator-1-0", networkKeyFile)); err != nil {
+		t.Fatalf("failed to remove network key: %v", err)
+	}
+	regeneratedSigner, regeneratedNetwork, err := m.nodeKeys("validator-1-0", true, false)
+	if err != nil {
+		t.Fatalf("nodeKeys: %v", err)
+	}
+	if !regeneratedSigner.PublicKey.Equals(signerKey.PublicKey) || !regeneratedNetwork.PublicKey.Equals(networkKey.PublicKey) {
+		t.Error("seeded keys changed after the network key was regenerated")
+	}
+
+	// Other instances get other keys
+	otherSigner, otherNetwork, err := m.nodeKeys("validator-2-0", true, false)
+	if err != nil {
+		t.Fatalf("nodeKeys: %v", err)
+	}
+	if otherSigner.PublicKey.Equals(signerKey.PublicKey) || otherNetwork.PublicKey.Equals(networkKey.PublicKey) {
+		t.Error("instances share keys")
+	}
+}
+
+func TestNodeKeysPersisted(t *testing.T) {
+	m := newTestManager(t, NewFakeProvider())
+
+	signerKey, networkKey, err := m.nodeKeys("validator-1-0", true, false)
+	if err != nil {
+		t.Fatalf("nodeKeys: %v", err)
+	}
+
+	// Random keys are reused by later runs
+	storedSigner, storedNetwork, err := m.nodeKeys("validator-1-0", true, false)
+	if err != nil {
+		t.Fatalf("nodeKeys: %v", err)
+	}
+	if !storedSigner.PublicKey.Equals(signerKey.PublicKey) || !storedNetwork.PublicKey.Equals(networkKey.PublicKey) {
+		t.Error("stored keys were not reused")
+	}
+
+	// Rotating replaces them
+	rotatedSigner, rotatedNetwork, err := m.nodeKeys("validator-1-0", true, true)
+	if err != nil {
+		t.Fatalf("nodeKeys: %v", err)
+	}
+	if rotatedSigner.PublicKey.Equals(signerKey.PublicKey) || rotatedNetwork.PublicKey.Equals(networkKey.PublicKey) {
+		t.Error("rotated keys are the same as before")
+	}
+}
+
+func TestNodeKeysSignerKeyType(t *testing.T) {
+	m := newTestManager(t, NewFakeProvider())
+	m.config.Genesis.ValidatorPubKeyTypes = []string{coretypes.ABCIPubKeyTypeSecp256k1}
+
+	signerKey, networkKey, err := m.nodeKeys("validator-1-0", true, false)
+	if err != nil {
+		t.Fatalf("nodeKeys: %v", err)
+	}
+	if got := signerKey.PublicKey.Type(); got != coretypes.ABCIPubKeyTypeSecp256k1 {
+		t.Errorf("signer key type = %s, want %s", got, coretypes.ABCIPubKeyTypeSecp256k1)
+	}
+	if got := networkKey.PublicKey.Type(); got != coretypes.ABCIPubKeyTypeEd25519 {
+		t.Errorf("network key type = %s, want %s", got, coretypes.ABCIPubKeyTypeEd25519)
+	}
+
+	// A stored key the chain no longer accepts is not used
+	m.config.Genesis.ValidatorPubKeyTypes = []string{coretypes.ABCIPubKeyTypeEd25519}
+	if _, _, err := m.nodeKeys("validator-1-0", true, false); err == nil || !strings.Contains(err.Error(), "--rotate-keys") {
+		t.Errorf("error = %v, want the stored key to be rejected", err)
+	}
+	if _, _, err := m.nodeKeys("validator-1-0", true, true); err != nil {
+		t.Errorf("nodeKeys with rotation: %v", err)
+	}
+}
+
+func TestNodeKeysZeroSeed(t *testing.T) {
+	m := newTestManager(t, NewFakeProvider())
+	seed := int64(0)
+	m.config.KeySeed = &seed
+
+	signerKey, _, err := m.nodeKeys("validator-1-0", true, false)
+	if err != nil {
+		t.Fatalf("nodeKeys: %v", err)
+	}
+	want := newSeededKeyGenerator(0, "validator-1-0/signer").Generate(ed25519Type)
+	if !signerKey.PublicKey.Equals(want.PublicKey) {
+		t.Error("zero seed generated a random key")
+	}
+}
+
+func TestRotateSeededKeys(t *testing.T) {
+	m := newTestManager(t, NewFakeProvider())
+	seed := int64(42)
+	m.config.KeySeed = &seed
+
+	err := m.SetupCelestiaNetwork(context.Background(), "test", true)
+	if err == nil || !strings.Contains(err.Error(), "cannot be rotated") {
+		t.Fatalf("error = %v, want rotation of seeded keys to be refused", err)
+	}
+}
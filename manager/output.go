@@ -0,0 +1,56 @@
+package manager
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// consoleMu serializes the lines streamed from concurrent remote commands so
+// that they do not interleave
+var consoleMu sync.Mutex
+
+// prefixWriter writes complete lines to the underlying writer, each prefixed
+// with the name of the host it came from
+type prefixWriter struct {
+	w      io.Writer
+	prefix string
+	buf    bytes.Buffer
+}
+
+// newPrefixWriter creates a prefixWriter that writes to w
+func newPrefixWriter(w io.Writer, prefix string) *prefixWriter {
+	return &prefixWriter{w: w, prefix: prefix}
+}
+
+// Write implements io.Writer. Incomplete lines are buffered until the next
+// newline or Flush.
+func (p *prefixWriter) Write(data []byte) (int, error) {
+	p.buf.Write(data)
+	for {
+		idx := bytes.IndexByte(p.buf.Bytes(), '\n')
+		if idx < 0 {
+			break
+		}
+		line := p.buf.Next(idx + 1)
+		p.writeLine(line[:len(line)-1])
+	}
+	return len(data), nil
+}
+
+// Flush writes any buffered incomplete line
+func (p *prefixWriter) Flush() {
+	if p.buf.Len() == 0 {
+		return
+	}
+	p.writeLine(p.buf.Bytes())
+	p.buf.Reset()
+}
+
+// writeLine writes a single prefixed line to the console
+func (p *prefixWriter) writeLine(line []byte) {
+	consoleMu.Lock()
+	defer consoleMu.Unlock()
+	fmt.Fprintf(p.w, "[%s] %s\n", p.prefix, bytes.TrimRight(line, "\r"))
+}
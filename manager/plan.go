@@ -0,0 +1,342 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/celestiaorg/talis-test/config"
+	"github.com/celestiaorg/talis/pkg/db/models"
+)
+
+// ActionKind is the kind of change a plan makes to an instance
+type ActionKind string
+
+const (
+	// ActionForget drops an instance from state that no longer exists in Talis
+	ActionForget ActionKind = "forget"
+	// ActionUpdate records the public IP Talis reports for an instance
+	ActionUpdate ActionKind = "update"
+	// ActionDelete deletes an instance that is no longer configured
+	ActionDelete ActionKind = "delete"
+	// ActionReplace deletes and recreates an instance whose configuration changed
+	ActionReplace ActionKind = "replace"
+	// ActionAdopt records a configured instance that exists in Talis but not in state
+	ActionAdopt ActionKind = "adopt"
+	// ActionCreate creates a configured instance that does not exist
+	ActionCreate ActionKind = "create"
+)
+
+// actionOrder is the order in which apply executes the actions. Deletes run
+// before creates so that a replaced instance can reuse its name.
+var actionOrder = []ActionKind{ActionForget, ActionUpdate, ActionDelete, ActionReplace, ActionAdopt, ActionCreate}
+
+// PlanAction is a single change to an instance
+type PlanAction struct {
+	Kind   ActionKind `json:"kind"`
+	Name   string     `json:"name"`
+	Reason string     `json:"reason"`
+
+	// definition is the desired configuration for replace, adopt and create
+	definition config.InstanceDefinition
+	// instance is the state entry for forget, update, delete and replace
+	instance InstanceInfo
+	// remote is the Talis instance for update, adopt and deletes of
+	// instances that are not in state
+	remote models.Instance
+	// tracked reports whether the instance is recorded in state
+	tracked bool
+}
+
+// Plan is the set of changes that reconciles the configuration, the state and
+// the instances in the Talis project
+type Plan struct {
+	Actions []PlanAction `json:"actions"`
+}
+
+// Empty reports whether the plan makes no changes
+func (p *Plan) Empty() bool {
+	return len(p.Actions) == 0
+}
+
+// Summary returns the number of actions of every kind
+func (p *Plan) Summary() string {
+	counts := make(map[ActionKind]int)
+	for _, action := range p.Actions {
+		counts[action.Kind]++
+	}
+
+	parts := make([]string, 0, len(actionOrder))
+	for _, kind := range actionOrder {
+		parts = append(parts, fmt.Sprintf("%d to %s", counts[kind], kind))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Plan compares the configured instances with the instances in state and in
+// the Talis project and returns the changes that reconcile them
+func (m *TalisManager) Plan(ctx context.Context) (*Plan, error) {
+	// Load state
+	state, err := m.LoadState()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load state: %w", err)
+	}
+	m.state = state
+
+	remotes, listed, err := m.remoteInstances(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	configured := make(map[string]config.InstanceDefinition)
+	for _, def := range m.config.Instances {
+		configured[instanceName(def)] = def
+	}
+
+	plan := &Plan{}
+	tracked := make(map[string]bool)
+	for _, instance := range m.state.Instances[m.config.ProjectName] {
+		remote, ok := remotes[instance.Name]
+		if listed && !ok {
+			plan.Actions = append(plan.Actions, PlanAction{
+				Kind:     ActionForget,
+				Name:     instance.Name,
+				Reason:   "recorded in state but not found in the Talis project",
+				instance: instance,
+				tracked:  true,
+			})
+			continue
+		}
+		tracked[instance.Name] = true
+
+		if ok && remote.PublicIP != "" && instance.PublicIP != "" && remote.PublicIP != instance.PublicIP {
+			plan.Actions = append(plan.Actions, PlanAction{
+				Kind:     ActionUpdate,
+				Name:     instance.Name,
+				Reason:   fmt.Sprintf("public IP changed from %s to %s", instance.PublicIP, remote.PublicIP),
+				instance: instance,
+				remote:   remote,
+				tracked:  true,
+			})
+		}
+
+		def, ok := configured[instance.Name]
+		if !ok {
+			plan.Actions = append(plan.Actions, PlanAction{
+				Kind:     ActionDelete,
+				Name:     instance.Name,
+				Reason:   "no longer configured",
+				instance: instance,
+				tracked:  true,
+			})
+			continue
+		}
+
+		if changes := definitionChanges(instance, def); len(changes) > 0 {
+			plan.Actions = append(plan.Actions, PlanAction{
+				Kind:       ActionReplace,
+				Name:       instance.Name,
+				Reason:     strings.Join(changes, ", "),
+				definition: def,
+				instance:   instance,
+				tracked:    true,
+			})
+		}
+	}
+
+	for _, def := range m.config.Instances {
+		name := instanceName(def)
+		if tracked[name] {
+			continue
+		}
+
+		if remote, ok := remotes[name]; ok {
+			plan.Actions = append(plan.Actions, PlanAction{
+				Kind:       ActionAdopt,
+				Name:       name,
+				Reason:     "found in the Talis project but not in state",
+				definition: def,
+				remote:     remote,
+			})
+			continue
+		}
+
+		plan.Actions = append(plan.Actions, PlanAction{
+			Kind:       ActionCreate,
+			Name:       name,
+			Reason:     "configured but not created",
+			definition: def,
+		})
+	}
+
+	var untracked []string
+	for name := range remotes {
+		if _, ok := configured[name]; !ok && !tracked[name] {
+			untracked = append(untracked, name)
+		}
+	}
+	sort.Strings(untracked)
+	for _, name := range untracked {
+		plan.Actions = append(plan.Actions, PlanAction{
+			Kind:   ActionDelete,
+			Name:   name,
+			Reason: "found in the Talis project but neither in state nor configured",
+			remote: remotes[name],
+		})
+	}
+
+	return plan, nil
+}
+
+// remoteInstances returns the live instances of the Talis project by name.
+// The second return value is false if the project was not created yet.
+func (m *TalisManager) remoteInstances(ctx context.Context) (map[string]models.Instance, bool, error) {
+	remotes := make(map[string]models.Instance)
+
+	projectName := m.state.Projects[m.config.ProjectName]
+	if m.state.UserID == 0 || projectName == "" {
+		return remotes, false, nil
+	}
+
+	instances, err := m.provider.ListInstances(ctx, projectName, m.state.UserID)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return remotes, false, nil
+		}
+		return nil, false, fmt.Errorf("failed to list project instances: %w", err)
+	}
+
+	for _, instance := range instances {
+		if instance.Status == models.InstanceStatusTerminated {
+			continue
+		}
+		remotes[instance.Name] = instance
+	}
+	return remotes, true, nil
+}
+
+// Apply executes the changes of the plan and waits for the created and adopted
+// instances to be ready
+func (m *TalisManager) Apply(ctx context.Context, plan *Plan) error {
+	if plan.Empty() {
+		log.Println("Nothing to apply, the deployment matches the configuration")
+		return nil
+	}
+
+	userID, projectName, err := m.ensureProject(ctx)
+	if err != nil {
+		return err
+	}
+
+	var instanceIDs []uint
+	var instanceDefs []config.InstanceDefinition
+	for _, kind := range actionOrder {
+		for _, action := range plan.Actions {
+			if action.Kind != kind {
+				continue
+			}
+
+			log.Printf("Applying %s of instance %s: %s", action.Kind, action.Name, action.Reason)
+			instanceID, err := m.applyAction(ctx, userID, projectName, action)
+			if err != nil {
+				return fmt.Errorf("failed to %s instance %s: %w", action.Kind, action.Name, err)
+			}
+			if instanceID != 0 {
+				instanceIDs = append(instanceIDs, instanceID)
+			}
+			if action.Kind == ActionReplace || action.Kind == ActionCreate {
+				instanceDefs = append(instanceDefs, action.definition)
+			}
+		}
+	}
+
+	// Replaced and new instances are created together once the deletes freed
+	// their names
+	createdIDs, err := m.createInstanceBatch(ctx, userID, projectName, instanceDefs)
+	if err != nil {
+		return err
+	}
+	instanceIDs = append(instanceIDs, createdIDs...)
+
+	// Instances created by an interrupted run may not have their IP yet
+	for _, instance := range m.state.Instances[m.config.ProjectName] {
+		if instance.PublicIP == "" && !slices.Contains(instanceIDs, instance.ID) {
+			instanceIDs = append(instanceIDs, instance.ID)
+		}
+	}
+	if len(instanceIDs) == 0 {
+		return nil
+	}
+
+	return m.provisionInstances(ctx, instanceIDs)
+}
+
+// applyAction executes a single action of a plan. It returns the ID of the
+// instance to wait for, if any. The instances of replace and create actions
+// are created afterwards by Apply in one batch.
+func (m *TalisManager) applyAction(ctx context.Context, userID uint, projectName string, action PlanAction) (uint, error) {
+	switch action.Kind {
+	case ActionForget:
+		if err := m.removeInstance(action.instance); err != nil {
+			return 0, err
+		}
+		return 0, nil
+
+	case ActionUpdate:
+		// The old IP may be handed out to another machine
+		if err := m.sshManager.Forget(action.instance.PublicIP); err != nil {
+			return 0, fmt.Errorf("failed to forget host key: %w", err)
+		}
+		return 0, m.updateInstance(action.Name, func(inst *InstanceInfo) {
+			inst.PublicIP = action.remote.PublicIP
+		})
+
+	case ActionDelete:
+		if action.tracked {
+			return 0, m.deleteInstances(ctx, userID, projectName, []uint{action.instance.ID})
+		}
+		return 0, m.provider.DeleteInstances(ctx, userID, projectName, []string{action.Name})
+
+	case ActionReplace:
+		return 0, m.deleteInstances(ctx, userID, projectName, []uint{action.instance.ID})
+
+	case ActionAdopt:
+		if err := m.addInstance(action.definition, action.remote.ID, action.remote.CreatedAt); err != nil {
+			return 0, err
+		}
+		return action.remote.ID, nil
+
+	case ActionCreate:
+		return 0, nil
+	}
+
+	return 0, fmt.Errorf("unknown action %s", action.Kind)
+}
+
+// removeInstance drops an instance from state without deleting it and forgets
+// its host key
+func (m *TalisManager) removeInstance(instance InstanceInfo) error {
+	if instance.PublicIP != "" {
+		if err := m.sshManager.Forget(instance.PublicIP); err != nil {
+			return fmt.Errorf("failed to forget host key: %w", err)
+		}
+	}
+
+	instances := m.state.Instances[m.config.ProjectName]
+	remaining := make([]InstanceInfo, 0, len(instances))
+	for _, inst := range instances {
+		if inst.Name != instance.Name {
+			remaining = append(remaining, inst)
+		}
+	}
+	m.state.Instances[m.config.ProjectName] = remaining
+
+	if err := m.SaveState(m.state); err != nil {
+		return fmt.Errorf("failed to save state: %w", err)
+	}
+	return nil
+}
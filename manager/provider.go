@@ -0,0 +1,41 @@
+package manager
+
+import (
+	"context"
+	"errors"
+
+	"github.com/celestiaorg/talis/pkg/db/models"
+	"github.com/celestiaorg/talis/pkg/types"
+)
+
+// ErrNotFound is returned by a Provider when the requested user, project or
+// instance does not exist
+var ErrNotFound = errors.New("not found")
+
+// Provider manages the lifecycle of the users, projects and instances backing
+// a deployment
+type Provider interface {
+	// GetUser returns the ID of the user with the given name
+	GetUser(ctx context.Context, username string) (uint, error)
+	// CreateUser creates a user and returns its ID
+	CreateUser(ctx context.Context, username string) (uint, error)
+
+	// GetProject returns the name of the project owned by the given user
+	GetProject(ctx context.Context, name string, ownerID uint) (string, error)
+	// CreateProject creates a project and returns its name
+	CreateProject(ctx context.Context, name, description string, ownerID uint) (string, error)
+
+	// CreateInstances requests the creation of instances. Creation is
+	// asynchronous, the instances show up as pending in ListInstances.
+	CreateInstances(ctx context.Context, requests []types.InstanceRequest) error
+	// GetInstance returns the instance with the given ID
+	GetInstance(ctx context.Context, id uint) (models.Instance, error)
+	// ListInstances returns all instances of a project
+	ListInstances(ctx context.Context, projectName string, ownerID uint) ([]models.Instance, error)
+	// DeleteInstances deletes the instances with the given names from a project
+	DeleteInstances(ctx context.Context, ownerID uint, projectName string, names []string) error
+
+	// ListTasks returns all tasks of a project, such as the creation of an
+	// instance along with the error it failed with
+	ListTasks(ctx context.Context, projectName string, ownerID uint) ([]models.Task, error)
+}
@@ -0,0 +1,340 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/celestiaorg/talis/pkg/db/models"
+	"github.com/celestiaorg/talis/pkg/types"
+)
+
+// FakeProvider is an in-memory Provider for tests. Created instances start
+// pending and move through provisioning to ready as they are polled with
+// GetInstance, receiving a public IP once they are ready.
+type FakeProvider struct {
+	// PollsPerTransition is the number of GetInstance calls an instance stays
+	// pending and then provisioning before moving on
+	PollsPerTransition int
+
+	mu        sync.Mutex
+	users     map[string]uint
+	projects  map[fakeProjectKey]string
+	instances map[uint]*fakeInstance
+	failures  map[string][]error
+	nextID    uint
+	nextIP    int
+}
+
+// fakeProjectKey identifies a project of the fake provider
+type fakeProjectKey struct {
+	ownerID uint
+	name    string
+}
+
+// fakeInstance is an instance of the fake provider, its creation task and
+// its poll count
+type fakeInstance struct {
+	instance    models.Instance
+	task        models.Task
+	projectName string
+	polls       int
+}
+
+// NewFakeProvider creates an empty FakeProvider
+func NewFakeProvider() *FakeProvider {
+	return &FakeProvider{
+		PollsPerTransition: 1,
+		users:              make(map[string]uint),
+		projects:           make(map[fakeProjectKey]string),
+		instances:          make(map[uint]*fakeInstance),
+		failures:           make(map[string][]error),
+		nextID:             1,
+		nextIP:             1,
+	}
+}
+
+// FailNext makes the next call of the given Provider method (e.g.
+// "CreateInstances") return err. Multiple failures for the same method are
+// returned in order, a nil err lets its call succeed.
+func (f *FakeProvider) FailNext(method string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failures[method] = append(f.failures[method], err)
+}
+
+// Terminate moves the instance with the given name to the terminated status,
+// as if the cloud provider failed to create it
+func (f *FakeProvider) Terminate(name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, inst := range f.instances {
+		if inst.instance.Name == name {
+			inst.instance.Status = models.InstanceStatusTerminated
+			return nil
+		}
+	}
+	return fmt.Errorf("instance %s: %w", name, ErrNotFound)
+}
+
+// FailCreation fails the creation task of the instance with the given name
+// with the given error message. The instance stays pending.
+func (f *FakeProvider) FailCreation(name, message string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, inst := range f.instances {
+		if inst.instance.Name == name {
+			inst.task.Status = models.TaskStatusFailed
+			inst.task.Error = message
+			return nil
+		}
+	}
+	return fmt.Errorf("instance %s: %w", name, ErrNotFound)
+}
+
+// Instances returns a snapshot of all instances ordered by ID
+func (f *FakeProvider) Instances() []models.Instance {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	instances := make([]models.Instance, 0, len(f.instances))
+	for _, inst := range f.instances {
+		instances = append(instances, inst.instance)
+	}
+	sort.Slice(instances, func(i, j int) bool { return instances[i].ID < instances[j].ID })
+	return instances
+}
+
+// GetUser implements Provider
+func (f *FakeProvider) GetUser(ctx context.Context, username string) (uint, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure("GetUser"); err != nil {
+		return 0, err
+	}
+	id, ok := f.users[username]
+	if !ok {
+		return 0, fmt.Errorf("user %s: %w", username, ErrNotFound)
+	}
+	return id, nil
+}
+
+// CreateUser implements Provider
+func (f *FakeProvider) CreateUser(ctx context.Context, username string) (uint, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure("CreateUser"); err != nil {
+		return 0, err
+	}
+	if _, ok := f.users[username]; ok {
+		return 0, fmt.Errorf("user %s already exists", username)
+	}
+	id := uint(len(f.users) + 1)
+	f.users[username] = id
+	return id, nil
+}
+
+// GetProject implements Provider
+func (f *FakeProvider) GetProject(ctx context.Context, name string, ownerID uint) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure("GetProject"); err != nil {
+		return "", err
+	}
+	if _, ok := f.projects[fakeProjectKey{ownerID, name}]; !ok {
+		return "", fmt.Errorf("project %s: %w", name, ErrNotFound)
+	}
+	return name, nil
+}
+
+// CreateProject implements Provider
+func (f *FakeProvider) CreateProject(ctx context.Context, name, description string, ownerID uint) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure("CreateProject"); err != nil {
+		return "", err
+	}
+	key := fakeProjectKey{ownerID, name}
+	if _, ok := f.projects[key]; ok {
+		return "", fmt.Errorf("project %s already exists", name)
+	}
+	f.projects[key] = description
+	return name, nil
+}
+
+// CreateInstances implements Provider
+func (f *FakeProvider) CreateInstances(ctx context.Context, requests []types.InstanceRequest) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure("CreateInstances"); err != nil {
+		return err
+	}
+	for _, req := range requests {
+		if _, ok := f.projects[fakeProjectKey{req.OwnerID, req.ProjectName}]; !ok {
+			return fmt.Errorf("project %s: %w", req.ProjectName, ErrNotFound)
+		}
+	}
+
+	for _, req := range requests {
+		count := req.NumberOfInstances
+		if count < 1 {
+			count = 1
+		}
+		// Talis names instances <name>-<index>
+		for i := 0; i < count; i++ {
+			var instance models.Instance
+			instance.ID = f.nextID
+			instance.OwnerID = req.OwnerID
+			instance.Name = fmt.Sprintf("%s-%d", req.Name, i)
+			instance.Region = req.Region
+			instance.Size = req.Size
+			instance.Image = req.Image
+			instance.Tags = req.Tags
+			instance.Status = models.InstanceStatusPending
+			instance.CreatedAt = time.Now()
+
+			// Talis keeps the request in the payload of the creation task,
+			// together with the ID of the created instance
+			taskReq := req
+			taskReq.Name = instance.Name
+			taskReq.InstanceID = instance.ID
+			payload, err := json.Marshal(taskReq)
+			if err != nil {
+				return err
+			}
+			var task models.Task
+			task.ID = f.nextID
+			task.OwnerID = req.OwnerID
+			task.Name = fmt.Sprintf("task-%d", f.nextID)
+			task.Action = models.TaskActionCreateInstances
+			task.Status = models.TaskStatusPending
+			task.Payload = payload
+			task.CreatedAt = instance.CreatedAt
+
+			f.instances[f.nextID] = &fakeInstance{instance: instance, task: task, projectName: req.ProjectName}
+			f.nextID++
+		}
+	}
+	return nil
+}
+
+// GetInstance implements Provider. Every call advances the instance towards
+// the ready status.
+func (f *FakeProvider) GetInstance(ctx context.Context, id uint) (models.Instance, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure("GetInstance"); err != nil {
+		return models.Instance{}, err
+	}
+	inst, ok := f.instances[id]
+	if !ok {
+		return models.Instance{}, fmt.Errorf("instance %d: %w", id, ErrNotFound)
+	}
+	f.advance(inst)
+	return inst.instance, nil
+}
+
+// ListInstances implements Provider
+func (f *FakeProvider) ListInstances(ctx context.Context, projectName string, ownerID uint) ([]models.Instance, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure("ListInstances"); err != nil {
+		return nil, err
+	}
+	if _, ok := f.projects[fakeProjectKey{ownerID, projectName}]; !ok {
+		return nil, fmt.Errorf("project %s: %w", projectName, ErrNotFound)
+	}
+
+	var instances []models.Instance
+	for _, inst := range f.instances {
+		if inst.projectName == projectName && inst.instance.OwnerID == ownerID {
+			instances = append(instances, inst.instance)
+		}
+	}
+	sort.Slice(instances, func(i, j int) bool { return instances[i].ID < instances[j].ID })
+	return instances, nil
+}
+
+// DeleteInstances implements Provider
+func (f *FakeProvider) DeleteInstances(ctx context.Context, ownerID uint, projectName string, names []string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure("DeleteInstances"); err != nil {
+		return err
+	}
+
+	ids := make([]uint, 0, len(names))
+	for _, name := range names {
+		found := false
+		for id, inst := range f.instances {
+			if inst.projectName == projectName && inst.instance.OwnerID == ownerID && inst.instance.Name == name {
+				ids = append(ids, id)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("instance %s: %w", name, ErrNotFound)
+		}
+	}
+
+	for _, id := range ids {
+		delete(f.instances, id)
+	}
+	return nil
+}
+
+// ListTasks implements Provider
+func (f *FakeProvider) ListTasks(ctx context.Context, projectName string, ownerID uint) ([]models.Task, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure("ListTasks"); err != nil {
+		return nil, err
+	}
+	if _, ok := f.projects[fakeProjectKey{ownerID, projectName}]; !ok {
+		return nil, fmt.Errorf("project %s: %w", projectName, ErrNotFound)
+	}
+
+	var tasks []models.Task
+	for _, inst := range f.instances {
+		if inst.projectName == projectName && inst.task.OwnerID == ownerID {
+			tasks = append(tasks, inst.task)
+		}
+	}
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].ID < tasks[j].ID })
+	return tasks, nil
+}
+
+// failure pops the next injected failure of the given method
+func (f *FakeProvider) failure(method string) error {
+	errs := f.failures[method]
+	if len(errs) == 0 {
+		return nil
+	}
+	f.failures[method] = errs[1:]
+	return errs[0]
+}
+
+// advance moves an instance one poll further through its lifecycle
+func (f *FakeProvider) advance(inst *fakeInstance) {
+	switch inst.instance.Status {
+	case models.InstanceStatusReady, models.InstanceStatusTerminated:
+		return
+	}
+	if inst.task.Status == models.TaskStatusFailed {
+		return
+	}
+
+	inst.polls++
+	switch {
+	case inst.polls > 2*f.PollsPerTransition:
+		inst.instance.Status = models.InstanceStatusReady
+		inst.instance.PublicIP = fmt.Sprintf("10.0.%d.%d", f.nextIP/256, f.nextIP%256)
+		inst.task.Status = models.TaskStatusCompleted
+		f.nextIP++
+	case inst.polls > f.PollsPerTransition:
+		inst.instance.Status = models.InstanceStatusProvisioning
+	}
+}
@@ -0,0 +1,139 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/celestiaorg/talis/pkg/api/v1/client"
+	"github.com/celestiaorg/talis/pkg/api/v1/handlers"
+	"github.com/celestiaorg/talis/pkg/db/models"
+	"github.com/celestiaorg/talis/pkg/types"
+)
+
+// TalisProvider is a Provider backed by the Talis API
+type TalisProvider struct {
+	client client.Client
+}
+
+// NewTalisProvider creates a new TalisProvider using the given client
+func NewTalisProvider(client client.Client) *TalisProvider {
+	return &TalisProvider{client: client}
+}
+
+// GetUser implements Provider
+func (p *TalisProvider) GetUser(ctx context.Context, username string) (uint, error) {
+	users, err := p.client.GetUsers(ctx, handlers.UserGetParams{
+		Username: username,
+	})
+	if err != nil {
+		return 0, talisError(err)
+	}
+	return users.User.ID, nil
+}
+
+// CreateUser implements Provider
+func (p *TalisProvider) CreateUser(ctx context.Context, username string) (uint, error) {
+	user, err := p.client.CreateUser(ctx, handlers.CreateUserParams{
+		Username: username,
+	})
+	if err != nil {
+		return 0, talisError(err)
+	}
+	return user.UserID, nil
+}
+
+// GetProject implements Provider
+func (p *TalisProvider) GetProject(ctx context.Context, name string, ownerID uint) (string, error) {
+	project, err := p.client.GetProject(ctx, handlers.ProjectGetParams{
+		Name:    name,
+		OwnerID: ownerID,
+	})
+	if err != nil {
+		return "", talisError(err)
+	}
+	return project.Name, nil
+}
+
+// CreateProject implements Provider
+func (p *TalisProvider) CreateProject(ctx context.Context, name, description string, ownerID uint) (string, error) {
+	project, err := p.client.CreateProject(ctx, handlers.ProjectCreateParams{
+		Name:        name,
+		Description: description,
+		OwnerID:     ownerID,
+	})
+	if err != nil {
+		return "", talisError(err)
+	}
+	return project.Name, nil
+}
+
+// CreateInstances implements Provider
+func (p *TalisProvider) CreateInstances(ctx context.Context, requests []types.InstanceRequest) error {
+	return talisError(p.client.CreateInstance(ctx, requests))
+}
+
+// GetInstance implements Provider
+func (p *TalisProvider) GetInstance(ctx context.Context, id uint) (models.Instance, error) {
+	instance, err := p.client.GetInstance(ctx, strconv.Itoa(int(id)))
+	if err != nil {
+		return models.Instance{}, talisError(err)
+	}
+	return instance, nil
+}
+
+// ListInstances implements Provider
+func (p *TalisProvider) ListInstances(ctx context.Context, projectName string, ownerID uint) ([]models.Instance, error) {
+	instances, err := p.client.ListProjectInstances(ctx, handlers.ProjectListInstancesParams{
+		Name:    projectName,
+		OwnerID: ownerID,
+	})
+	if err != nil {
+		return nil, talisError(err)
+	}
+	return instances, nil
+}
+
+// DeleteInstances implements Provider
+func (p *TalisProvider) DeleteInstances(ctx context.Context, ownerID uint, projectName string, names []string) error {
+	return talisError(p.client.DeleteInstances(ctx, types.DeleteInstancesRequest{
+		OwnerID:       ownerID,
+		ProjectName:   projectName,
+		InstanceNames: names,
+	}))
+}
+
+// talisTaskPageSize is the number of tasks the Talis API returns per page
+const talisTaskPageSize = 50
+
+// ListTasks implements Provider
+func (p *TalisProvider) ListTasks(ctx context.Context, projectName string, ownerID uint) ([]models.Task, error) {
+	var tasks []models.Task
+	for page := 1; ; page++ {
+		batch, err := p.client.ListTasks(ctx, handlers.TaskListParams{
+			ProjectName: projectName,
+			OwnerID:     ownerID,
+			Page:        page,
+		})
+		if err != nil {
+			return nil, talisError(err)
+		}
+		tasks = append(tasks, batch...)
+		if len(batch) < talisTaskPageSize {
+			return tasks, nil
+		}
+	}
+}
+
+// talisError wraps 404 responses of the Talis API in ErrNotFound
+func talisError(err error) error {
+	if err == nil {
+		return nil
+	}
+	// The client does not expose the status code, check the error body
+	if strings.Contains(err.Error(), "\"code\":404") {
+		return fmt.Errorf("%w: %v", ErrNotFound, err)
+	}
+	return err
+}
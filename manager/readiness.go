@@ -0,0 +1,205 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/celestiaorg/talis/pkg/db/models"
+	"github.com/celestiaorg/talis/pkg/types"
+)
+
+const (
+	// readinessMinInterval is the interval between the first polls of an
+	// instance that is not ready yet. It doubles after every poll.
+	readinessMinInterval = 2 * time.Second
+	// readinessMaxInterval bounds the interval between two polls
+	readinessMaxInterval = 30 * time.Second
+)
+
+// waitForInstancesToBeReady waits for all instances to be ready and to accept
+// SSH connections. The instances are polled concurrently with a growing
+// interval. It fails as soon as an instance is terminated or deleted or its
+// creation task failed. A zero timeout waits indefinitely.
+func (m *TalisManager) waitForInstancesToBeReady(ctx context.Context, instanceIDs []uint, timeout time.Duration) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	// The first failure stops waiting for the other instances
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	tasks := &taskWatcher{
+		provider:    m.provider,
+		userID:      m.state.UserID,
+		projectName: m.state.Projects[m.config.ProjectName],
+	}
+
+	// Create a semaphore to limit concurrent API calls
+	sem := make(chan struct{}, 10)
+	errChan := make(chan error, len(instanceIDs))
+	var wg sync.WaitGroup
+
+	for _, instanceID := range instanceIDs {
+		wg.Add(1)
+		go func(id uint) {
+			defer wg.Done()
+
+			if err := m.waitForInstance(ctx, id, sem, tasks); err != nil {
+				errChan <- err
+				cancel()
+			}
+		}(instanceID)
+	}
+
+	wg.Wait()
+	close(errChan)
+
+	// The first error is the failure that canceled the others
+	if err, ok := <-errChan; ok {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return fmt.Errorf("instances not ready after %v: %w", timeout, err)
+		}
+		return err
+	}
+
+	log.Println("All instances are ready!")
+	return nil
+}
+
+// waitForInstance polls a single instance until it is ready and then waits
+// for its SSH server
+func (m *TalisManager) waitForInstance(ctx context.Context, instanceID uint, sem chan struct{}, tasks *taskWatcher) error {
+	interval := readinessMinInterval
+	lastStatus := models.InstanceStatusUnknown
+	for {
+		sem <- struct{}{}
+		instance, err := m.provider.GetInstance(ctx, instanceID)
+		<-sem
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				return fmt.Errorf("instance %d was deleted while waiting for it to be ready: %w", instanceID, err)
+			}
+			return fmt.Errorf("failed to get instance %d: %w", instanceID, err)
+		}
+
+		if instance.Status != lastStatus {
+			log.Printf("Instance %s status: %s", instance.Name, instance.Status)
+			lastStatus = instance.Status
+		}
+
+		switch instance.Status {
+		case models.InstanceStatusReady:
+			if instance.PublicIP != "" {
+				return m.waitForSSH(ctx, instance)
+			}
+		case models.InstanceStatusTerminated:
+			return fmt.Errorf("instance %s was terminated while waiting for it to be ready", instance.Name)
+		default:
+			task, err := tasks.failedCreation(ctx, instance)
+			if err != nil {
+				return err
+			}
+			if task != nil {
+				return fmt.Errorf("failed to create instance %s: task %s failed: %s", instance.Name, task.Name, task.Error)
+			}
+		}
+
+		if err := sleepContext(ctx, interval); err != nil {
+			return fmt.Errorf("instance %s not ready: %w", instance.Name, err)
+		}
+		interval = min(2*interval, readinessMaxInterval)
+	}
+}
+
+// waitForSSH waits until the SSH server of a ready instance accepts a
+// connection. Talis reports instances as ready before sshd is up.
+func (m *TalisManager) waitForSSH(ctx context.Context, instance models.Instance) error {
+	log.Printf("Waiting for SSH on instance %s (%s)...", instance.Name, instance.PublicIP)
+
+	interval := readinessMinInterval
+	for {
+		err := m.checkSSH(ctx, instance.PublicIP)
+		if err == nil {
+			log.Printf("Instance %s (%s) is reachable over SSH", instance.Name, instance.PublicIP)
+			return nil
+		}
+
+		// A changed host key does not go away by retrying
+		var mismatch *HostKeyMismatchError
+		if errors.As(err, &mismatch) {
+			return err
+		}
+
+		if err := sleepContext(ctx, interval); err != nil {
+			return fmt.Errorf("instance %s (%s) not reachable over SSH: %w", instance.Name, instance.PublicIP, err)
+		}
+		interval = min(2*interval, readinessMaxInterval)
+	}
+}
+
+// taskWatcher looks up the failed instance creation tasks of a project. The
+// task list is shared by all instances and fetched at most once per
+// readinessMinInterval.
+type taskWatcher struct {
+	provider    Provider
+	userID      uint
+	projectName string
+
+	mu        sync.Mutex
+	fetchedAt time.Time
+	failed    map[uint]models.Task
+}
+
+// failedCreation returns the failed creation task of the instance, or nil if
+// there is none. Talis records the ID of the created instance in the payload
+// of its task, so tasks of earlier instances with the same name do not match.
+func (w *taskWatcher) failedCreation(ctx context.Context, instance models.Instance) (*models.Task, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if time.Since(w.fetchedAt) >= readinessMinInterval {
+		tasks, err := w.provider.ListTasks(ctx, w.projectName, w.userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list project tasks: %w", err)
+		}
+
+		w.failed = make(map[uint]models.Task)
+		for _, task := range tasks {
+			if task.Action != models.TaskActionCreateInstances || task.Status != models.TaskStatusFailed {
+				continue
+			}
+			var request types.InstanceRequest
+			if err := json.Unmarshal(task.Payload, &request); err != nil || request.InstanceID == 0 {
+				continue
+			}
+			w.failed[request.InstanceID] = task
+		}
+		w.fetchedAt = time.Now()
+	}
+
+	if task, ok := w.failed[instance.ID]; ok {
+		return &task, nil
+	}
+	return nil, nil
+}
+
+// sleepContext waits for the duration or until the context is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
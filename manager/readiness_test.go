@@ -0,0 +1,127 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/celestiaorg/talis-test/config"
+)
+
+// createPendingInstances creates instances with the fake provider that stay
+// pending until they are polled again and returns their IDs
+func createPendingInstances(t *testing.T, m *TalisManager, provider *FakeProvider, names ...string) []uint {
+	t.Helper()
+	provider.PollsPerTransition = 1
+
+	state, err := m.LoadState()
+	if err != nil {
+		t.Fatalf("failed to load state: %v", err)
+	}
+	m.state = state
+	userID, projectName, err := m.ensureProject(context.Background())
+	if err != nil {
+		t.Fatalf("ensureProject: %v", err)
+	}
+
+	var defs []config.InstanceDefinition
+	for _, name := range names {
+		defs = append(defs, config.NewInstanceDefinition(name, true, false).WithRole(config.ValidatorNode))
+	}
+	ids, err := m.createInstanceBatch(context.Background(), userID, projectName, defs)
+	if err != nil {
+		t.Fatalf("createInstanceBatch: %v", err)
+	}
+	return ids
+}
+
+func TestWaitForInstancesToBeReady(t *testing.T) {
+	provider := NewFakeProvider()
+	m := newTestManager(t, provider)
+	ids := createPendingInstances(t, m, provider, "validator-1", "validator-2")
+
+	var mu sync.Mutex
+	var checked []string
+	m.checkSSH = func(ctx context.Context, host string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		checked = append(checked, host)
+		return nil
+	}
+
+	if err := m.waitForInstancesToBeReady(context.Background(), ids, 30*time.Second); err != nil {
+		t.Fatalf("waitForInstancesToBeReady: %v", err)
+	}
+	if len(checked) != 2 {
+		t.Errorf("checked SSH on %d hosts, want 2", len(checked))
+	}
+}
+
+func TestWaitForInstancesToBeReadyFailedCreation(t *testing.T) {
+	provider := NewFakeProvider()
+	m := newTestManager(t, provider)
+	ids := createPendingInstances(t, m, provider, "validator-1", "validator-2")
+
+	const message = "droplet limit exceeded in nyc1"
+	if err := provider.FailCreation("validator-1-0", message); err != nil {
+		t.Fatalf("FailCreation: %v", err)
+	}
+
+	// The failure stops waiting for the instance that is still pending
+	err := m.waitForInstancesToBeReady(context.Background(), ids, 30*time.Second)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if !strings.Contains(err.Error(), message) || !strings.Contains(err.Error(), "validator-1-0") {
+		t.Errorf("error = %v, want the task error of validator-1-0", err)
+	}
+}
+
+func TestWaitForInstancesToBeReadyTerminated(t *testing.T) {
+	provider := NewFakeProvider()
+	m := newTestManager(t, provider)
+	ids := createPendingInstances(t, m, provider, "validator-1")
+
+	if err := provider.Terminate("validator-1-0"); err != nil {
+		t.Fatalf("Terminate: %v", err)
+	}
+
+	err := m.waitForInstancesToBeReady(context.Background(), ids, 30*time.Second)
+	if err == nil || !strings.Contains(err.Error(), "validator-1-0 was terminated") {
+		t.Fatalf("error = %v, want validator-1-0 to be reported as terminated", err)
+	}
+}
+
+func TestWaitForInstancesToBeReadyDeleted(t *testing.T) {
+	provider := NewFakeProvider()
+	m := newTestManager(t, provider)
+	ids := createPendingInstances(t, m, provider, "validator-1")
+
+	err := provider.DeleteInstances(context.Background(), m.state.UserID, m.state.Projects[m.config.ProjectName], []string{"validator-1-0"})
+	if err != nil {
+		t.Fatalf("DeleteInstances: %v", err)
+	}
+
+	err = m.waitForInstancesToBeReady(context.Background(), ids, 30*time.Second)
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("error = %v, want %v", err, ErrNotFound)
+	}
+	if !strings.Contains(err.Error(), "was deleted") {
+		t.Errorf("error = %v, want the instance to be reported as deleted", err)
+	}
+}
+
+func TestWaitForInstancesToBeReadyTimeout(t *testing.T) {
+	provider := NewFakeProvider()
+	m := newTestManager(t, provider)
+	ids := createPendingInstances(t, m, provider, "validator-1")
+	provider.PollsPerTransition = 100
+
+	err := m.waitForInstancesToBeReady(context.Background(), ids, 100*time.Millisecond)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
@@ -0,0 +1,135 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// rpcClient is a minimal client for the CometBFT RPC endpoint of the nodes
+var rpcClient = &http.Client{Timeout: 10 * time.Second}
+
+// rpcGet queries the CometBFT RPC of the node at the given IP and decodes the
+// result field of the JSON-RPC response into out
+func rpcGet(ctx context.Context, ip, path string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("http://%s:26657/%s", ip, path), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := rpcClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to query %s on %s: %w", path, ip, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response from %s: %w", ip, err)
+	}
+
+	var rpcResp struct {
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Message string `json:"message"`
+			Data    string `json:"data"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &rpcResp); err != nil {
+		return fmt.Errorf("failed to decode response from %s: %w", ip, err)
+	}
+	if rpcResp.Error != nil {
+		return fmt.Errorf("rpc error from %s: %s %s", ip, rpcResp.Error.Message, rpcResp.Error.Data)
+	}
+
+	if err := json.Unmarshal(rpcResp.Result, out); err != nil {
+		return fmt.Errorf("failed to decode result from %s: %w", ip, err)
+	}
+	return nil
+}
+
+// blockHash returns the hash of the block at the given height, or of the
+// latest block if height is zero
+func blockHash(ctx context.Context, ip string, height int64) (string, error) {
+	var result struct {
+		BlockID struct {
+			Hash string `json:"hash"`
+		} `json:"block_id"`
+	}
+	path := "block"
+	if height > 0 {
+		path = fmt.Sprintf("block?height=%d", height)
+	}
+	if err := rpcGet(ctx, ip, path, &result); err != nil {
+		return "", err
+	}
+	if result.BlockID.Hash == "" {
+		return "", fmt.Errorf("block %d not available on %s", height, ip)
+	}
+	return result.BlockID.Hash, nil
+}
+
+// waitForGenesisHash waits until the node at the given IP has produced the
+// first block and returns its hash. A zero timeout waits until the context is
+// done.
+func waitForGenesisHash(ctx context.Context, ip string, timeout time.Duration) (string, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		hash, err := blockHash(ctx, ip, 1)
+		if err == nil {
+			return hash, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("first block not available on %s after %v: %w", ip, timeout, err)
+		case <-ticker.C:
+		}
+	}
+}
+
+// waitForCatchUp waits until the node at the given IP has caught up with the
+// network. A zero timeout waits until the context is done.
+func waitForCatchUp(ctx context.Context, ip string, timeout time.Duration) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	var status struct {
+		SyncInfo struct {
+			LatestBlockHeight int64 `json:"latest_block_height,string"`
+			CatchingUp        bool  `json:"catching_up"`
+		} `json:"sync_info"`
+	}
+	for {
+		err := rpcGet(ctx, ip, "status", &status)
+		if err == nil && !status.SyncInfo.CatchingUp && status.SyncInfo.LatestBlockHeight > 0 {
+			return nil
+		}
+		if err == nil {
+			err = fmt.Errorf("catching up at height %d", status.SyncInfo.LatestBlockHeight)
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("node %s not caught up after %v: %w", ip, timeout, err)
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,307 @@
+package manager
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/celestiaorg/celestia-app/v3/app"
+	"github.com/celestiaorg/celestia-app/v3/app/encoding"
+	"github.com/celestiaorg/talis-test/config"
+	"github.com/cosmos/cosmos-sdk/crypto/hd"
+	"github.com/cosmos/cosmos-sdk/crypto/keyring"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/tendermint/tendermint/crypto/ed25519"
+	"github.com/tendermint/tendermint/p2p"
+)
+
+const (
+	// joinFeeBudget is the amount in utia a joining validator is funded with
+	// on top of its stake to pay for its transactions
+	joinFeeBudget = int64(1e9)
+	// defaultCommissionRate is the commission rate of joining validators
+	defaultCommissionRate = 0.1
+)
+
+// JoinValidators adds the configured validators that are not part of the
+// network yet to the running network. Each one receives the existing genesis
+// and the validators as peers, is funded from the account of a genesis
+// validator once it caught up and then bonds with a create-validator
+// transaction.
+func (m *TalisManager) JoinValidators(ctx context.Context) error {
+	// Load state
+	state, err := m.LoadState()
+	if err != nil {
+		return fmt.Errorf("failed to load state: %w", err)
+	}
+	m.state = state
+
+	// Check instances against configuration
+	if err := m.checkInstances(); err != nil {
+		return err
+	}
+
+	genesisPath, err := m.genesisPath()
+	if err != nil {
+		return err
+	}
+	genesisJSON, err := os.ReadFile(genesisPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("no genesis recorded for environment %s, run genesis first", m.config.Environment)
+		}
+		return fmt.Errorf("failed to read genesis file: %w", err)
+	}
+
+	// The chain ID may have been overridden when the genesis was created
+	var genesisDoc struct {
+		ChainID  string `json:"chain_id"`
+		AppState struct {
+			Staking struct {
+				Params struct {
+					MinCommissionRate string `json:"min_commission_rate"`
+				} `json:"params"`
+			} `json:"staking"`
+		} `json:"app_state"`
+	}
+	if err := json.Unmarshal(genesisJSON, &genesisDoc); err != nil {
+		return fmt.Errorf("failed to parse genesis file: %w", err)
+	}
+
+	// New validators charge the default commission unless the genesis
+	// requires more
+	commission := defaultCommissionRate
+	if rate := genesisDoc.AppState.Staking.Params.MinCommissionRate; rate != "" {
+		minRate, err := strconv.ParseFloat(rate, 64)
+		if err != nil {
+			return fmt.Errorf("failed to parse min commission rate %q: %w", rate, err)
+		}
+		commission = max(commission, minRate)
+	}
+
+	var funder *InstanceInfo
+	var peers, joining []InstanceInfo
+	for _, instance := range m.state.Instances[m.config.ProjectName] {
+		if instance.Role != config.ValidatorNode {
+			continue
+		}
+		if instance.HasStage(StageGenesis) || instance.HasStage(StageJoined) {
+			peers = append(peers, instance)
+			if funder == nil && instance.KeyName != "" && instance.HasStage(StageStarted) {
+				inst := instance
+				funder = &inst
+			}
+			continue
+		}
+		if instance.PublicIP == "" {
+			return fmt.Errorf("instance %s has no public IP", instance.Name)
+		}
+		joining = append(joining, instance)
+	}
+
+	if len(joining) == 0 {
+		log.Println("No new validators to add")
+		return nil
+	}
+	if funder == nil {
+		return fmt.Errorf("no running validator with an account key to fund the new validators, the network must be set up with the genesis command of this version")
+	}
+
+	peerAddresses := make([]string, 0, len(peers))
+	for _, peer := range peers {
+		address, err := peerAddress(peer)
+		if err != nil {
+			return err
+		}
+		peerAddresses = append(peerAddresses, address)
+	}
+
+	// Validators join one after the other since they are all funded from the
+	// same account
+	for _, instance := range joining {
+		log.Printf("Adding validator %s (%s) to the network...", instance.Name, instance.PublicIP)
+		if err := m.joinValidator(ctx, instance, *funder, peerAddresses, genesisJSON, genesisDoc.ChainID, commission); err != nil {
+			return fmt.Errorf("failed to add validator %s: %w", instance.Name, err)
+		}
+		log.Printf("Validator %s (%s) joined the network", instance.Name, instance.PublicIP)
+	}
+
+	return nil
+}
+
+// joinValidator sets up a single validator on a running network and bonds it
+func (m *TalisManager) joinValidator(ctx context.Context, inst, funder InstanceInfo, peers []string, genesisJSON []byte, chainID string, commission float64) error {
+	// A retried join keeps the keys of the first attempt
+	signerKey, networkKey, err := m.nodeKeys(inst.Name, true, false)
+	if err != nil {
+		return err
+	}
+	node := &CelestiaNode{
+		name:       inst.Name,
+		signerKey:  signerKey,
+		networkKey: networkKey,
+		sshManager: m.sshManager,
+		homeDir:    "/root/.celestia-app",
+		publicIP:   inst.PublicIP,
+	}
+
+	if err := node.setupNode(ctx); err != nil {
+		return fmt.Errorf("failed to setup node: %w", err)
+	}
+	if err := node.setupConfig(ctx, peers, 0); err != nil {
+		return fmt.Errorf("failed to setup config: %w", err)
+	}
+	if err := node.writeGenesis(ctx, genesisJSON); err != nil {
+		return err
+	}
+	err = m.updateInstance(inst.Name, func(info *InstanceInfo) {
+		info.ValidatorPubKey = node.ValidatorPubKey()
+		info.NetworkPubKey = node.NetworkPubKey()
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := m.startCelestiaAppServices(ctx, []InstanceInfo{inst}); err != nil {
+		return err
+	}
+
+	log.Printf("Waiting for %s (%s) to catch up...", inst.Name, inst.PublicIP)
+	if err := waitForCatchUp(ctx, inst.PublicIP, m.config.Timeouts.ChainStart); err != nil {
+		return err
+	}
+
+	// Create the account key of the validator unless a previous attempt did,
+	// or recover the imported one
+	keys := fmt.Sprintf("%s keys --keyring-backend test --home %s", appdBinary, node.homeDir)
+	mnemonic, err := m.accountMnemonic(inst.Name)
+	if err != nil {
+		return err
+	}
+	if mnemonic != "" {
+		kr := keyring.NewInMemory(encoding.MakeConfig(app.ModuleBasics).Codec)
+		if _, err := kr.NewAccount(inst.Name, mnemonic, keyring.DefaultBIP39Passphrase, sdk.FullFundraiserPath, hd.Secp256k1); err != nil {
+			return fmt.Errorf("failed to recover account from mnemonic: %w", err)
+		}
+		if err := importKey(ctx, m.sshManager, inst.PublicIP, node.homeDir, kr, inst.Name); err != nil {
+			return fmt.Errorf("failed to import account key: %w", err)
+		}
+	}
+	output, err := m.sshManager.ExecuteCommandWithOutput(ctx, inst.PublicIP,
+		fmt.Sprintf("%s show %s -a 2>/dev/null || (%s add %s > /dev/null 2>&1 && %s show %s -a)", keys, inst.Name, keys, inst.Name, keys, inst.Name))
+	if err != nil {
+		return fmt.Errorf("failed to create account key: %w", err)
+	}
+	address := strings.TrimSpace(output)
+
+	// An interrupted earlier attempt may have created the validator without
+	// recording it, funding it again would be wasted and create-validator fail
+	exists, err := m.validatorExists(ctx, inst.PublicIP, keys, inst.Name)
+	if err != nil {
+		return err
+	}
+	if exists {
+		log.Printf("Validator %s already exists", inst.Name)
+		return m.updateInstance(inst.Name, func(info *InstanceInfo) {
+			info.KeyName = inst.Name
+			info.setStage(StageJoined)
+		})
+	}
+
+	// Joining validators are not part of the genesis stake distribution. They
+	// are funded with their configured balance, but at least enough to pay
+	// for their self-delegation and transactions.
+	selfDelegation, balance := validatorStake, int64(0)
+	if def, ok := m.configuredDefinition(inst.Name); ok {
+		if def.Stake > 0 {
+			selfDelegation = def.Stake
+		}
+		balance = def.Balance
+	}
+
+	log.Printf("Funding %s from %s...", address, funder.Name)
+	amount := fmt.Sprintf("%d%s", max(balance, selfDelegation+joinFeeBudget), app.BondDenom)
+	if err := m.submitTx(ctx, funder.PublicIP, chainID, fmt.Sprintf("bank send %s %s %s", funder.KeyName, address, amount)); err != nil {
+		return fmt.Errorf("failed to fund account: %w", err)
+	}
+
+	log.Printf("Submitting create-validator for %s...", inst.Name)
+	stake := fmt.Sprintf("%d%s", selfDelegation, app.BondDenom)
+	createValidator := fmt.Sprintf(`staking create-validator --from %s --amount %s --pubkey "$(%s tendermint show-validator --home %s)" `+
+		`--moniker %s --commission-rate %s --commission-max-rate %s --commission-max-change-rate 0.01 --min-self-delegation 1`,
+		inst.Name, stake, appdBinary, node.homeDir, inst.Name,
+		strconv.FormatFloat(commission, 'f', -1, 64), strconv.FormatFloat(max(commission, 2*defaultCommissionRate), 'f', -1, 64))
+	if err := m.submitTx(ctx, inst.PublicIP, chainID, createValidator); err != nil {
+		return fmt.Errorf("failed to create validator: %w", err)
+	}
+
+	return m.updateInstance(inst.Name, func(info *InstanceInfo) {
+		info.KeyName = inst.Name
+		info.setStage(StageJoined)
+	})
+}
+
+// validatorExists reports whether the account key of an instance operates a
+// validator on the chain
+func (m *TalisManager) validatorExists(ctx context.Context, host, keys, name string) (bool, error) {
+	// The query fails for unknown validators
+	cmd := fmt.Sprintf(`%s query staking validator "$(%s show %s --bech val -a)" --output json 2>/dev/null || true`,
+		appdBinary, keys, name)
+	output, err := m.sshManager.ExecuteCommandWithOutput(ctx, host, cmd)
+	if err != nil {
+		return false, fmt.Errorf("failed to query validator %s: %w", name, err)
+	}
+	if strings.TrimSpace(output) == "" {
+		return false, nil
+	}
+
+	var validator struct {
+		OperatorAddress string `json:"operator_address"`
+	}
+	if err := json.Unmarshal([]byte(output), &validator); err != nil {
+		return false, fmt.Errorf("failed to decode validator %s %q: %w", name, strings.TrimSpace(output), err)
+	}
+	return validator.OperatorAddress != "", nil
+}
+
+// submitTx signs a transaction with the test keyring of the instance, waits
+// for it to be included in a block and checks its result code
+func (m *TalisManager) submitTx(ctx context.Context, host, chainID, tx string) error {
+	cmd := fmt.Sprintf("%s tx %s --keyring-backend test --home /root/.celestia-app --chain-id %s "+
+		"--gas auto --gas-adjustment 1.5 --gas-prices 0.002%s --broadcast-mode block --output json -y",
+		appdBinary, tx, chainID, app.BondDenom)
+	output, err := m.sshManager.ExecuteCommandWithOutput(ctx, host, cmd)
+	if err != nil {
+		return err
+	}
+
+	var result struct {
+		TxHash string `json:"txhash"`
+		Code   uint32 `json:"code"`
+		RawLog string `json:"raw_log"`
+	}
+	if err := json.Unmarshal([]byte(output), &result); err != nil {
+		return fmt.Errorf("failed to decode transaction result %q: %w", strings.TrimSpace(output), err)
+	}
+	if result.Code != 0 {
+		return fmt.Errorf("transaction %s failed with code %d: %s", result.TxHash, result.Code, result.RawLog)
+	}
+	log.Printf("Transaction %s committed", result.TxHash)
+	return nil
+}
+
+// peerAddress returns the persistent peer address of an instance from the
+// network key recorded in state
+func peerAddress(inst InstanceInfo) (string, error) {
+	pubKey, err := base64.StdEncoding.DecodeString(inst.NetworkPubKey)
+	if err != nil || len(pubKey) != ed25519.PubKeySize {
+		return "", fmt.Errorf("no valid network key recorded for instance %s", inst.Name)
+	}
+	id := p2p.PubKeyToID(ed25519.PubKey(pubKey))
+	return fmt.Sprintf("%s@%s:26656", id, inst.PublicIP), nil
+}
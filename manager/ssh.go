@@ -2,11 +2,20 @@ package manager
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/pkg/sftp"
 	"golang.org/x/crypto/ssh"
 )
 
@@ -22,140 +31,328 @@ func expandPath(path string) string {
 	return os.ExpandEnv(path)
 }
 
+// defaultSSHDialTimeout bounds establishing the TCP connection and SSH
+// handshake when no dial timeout is configured
+const defaultSSHDialTimeout = 30 * time.Second
+
 // SSHConfig holds SSH configuration
 type SSHConfig struct {
 	Username   string
 	PrivateKey string
+	// KnownHostsPath is the known_hosts file host keys are verified against
+	KnownHostsPath string
+	// DialTimeout bounds connecting and authenticating to a host
+	DialTimeout time.Duration
+	// CommandTimeout bounds a single remote command, zero means no limit
+	CommandTimeout time.Duration
 }
 
-// SSHManager handles SSH operations
+// SSHManager handles SSH operations. It keeps one authenticated connection
+// per host and runs every command in its own session over that connection.
 type SSHManager struct {
 	config SSHConfig
+
+	signerOnce sync.Once
+	signer     ssh.Signer
+	signerErr  error
+
+	knownHostsMu sync.Mutex
+
+	mu    sync.Mutex
+	conns map[string]*sshConn
+}
+
+// sshConn is the pooled connection to a single host
+type sshConn struct {
+	mu     sync.Mutex
+	client *ssh.Client
 }
 
 // NewSSHManager creates a new SSHManager instance
 func NewSSHManager(config SSHConfig) *SSHManager {
 	// Expand the private key path
 	config.PrivateKey = expandPath(config.PrivateKey)
+	if config.DialTimeout == 0 {
+		config.DialTimeout = defaultSSHDialTimeout
+	}
 	return &SSHManager{
 		config: config,
+		conns:  make(map[string]*sshConn),
 	}
 }
 
-// ExecuteCommand executes a command on a remote server via SSH
-func (s *SSHManager) ExecuteCommand(host string, command string) error {
-	// Read private key
-	key, err := os.ReadFile(s.config.PrivateKey)
-	if err != nil {
-		return fmt.Errorf("failed to read private key from %s: %w", s.config.PrivateKey, err)
+// Close closes all pooled connections
+func (s *SSHManager) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var errs []error
+	for host, conn := range s.conns {
+		conn.mu.Lock()
+		if conn.client != nil {
+			if err := conn.client.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("failed to close connection to %s: %w", host, err))
+			}
+			conn.client = nil
+		}
+		conn.mu.Unlock()
 	}
+	s.conns = make(map[string]*sshConn)
 
-	// Create signer
-	signer, err := ssh.ParsePrivateKey(key)
-	if err != nil {
-		return fmt.Errorf("failed to parse private key: %w", err)
+	return errors.Join(errs...)
+}
+
+// clientConfig returns the SSH client configuration, reading and parsing the
+// private key on first use only
+func (s *SSHManager) clientConfig() (*ssh.ClientConfig, error) {
+	s.signerOnce.Do(func() {
+		// Read private key
+		key, err := os.ReadFile(s.config.PrivateKey)
+		if err != nil {
+			s.signerErr = fmt.Errorf("failed to read private key from %s: %w", s.config.PrivateKey, err)
+			return
+		}
+
+		// Create signer
+		s.signer, err = ssh.ParsePrivateKey(key)
+		if err != nil {
+			s.signerErr = fmt.Errorf("failed to parse private key: %w", err)
+		}
+	})
+	if s.signerErr != nil {
+		return nil, s.signerErr
 	}
 
-	// SSH client config
-	config := &ssh.ClientConfig{
+	return &ssh.ClientConfig{
 		User: s.config.Username,
 		Auth: []ssh.AuthMethod{
-			ssh.PublicKeys(signer),
+			ssh.PublicKeys(s.signer),
 		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(), // Note: In production, use proper host key verification
+		HostKeyCallback: s.hostKeyCallback,
+	}, nil
+}
+
+// client returns the pooled client for the host, dialing it if there is no
+// connection yet
+func (s *SSHManager) client(ctx context.Context, host string) (*ssh.Client, error) {
+	s.mu.Lock()
+	conn, ok := s.conns[host]
+	if !ok {
+		conn = &sshConn{}
+		s.conns[host] = conn
 	}
+	s.mu.Unlock()
 
-	// Connect to server
-	client, err := ssh.Dial("tcp", host+":22", config)
+	// Dial outside of the pool lock so that hosts connect in parallel
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	if conn.client != nil {
+		return conn.client, nil
+	}
+
+	config, err := s.clientConfig()
 	if err != nil {
-		return fmt.Errorf("failed to dial: %w", err)
+		return nil, err
 	}
-	defer client.Close()
 
-	// Create session
-	session, err := client.NewSession()
+	client, err := s.dial(ctx, host, config)
 	if err != nil {
-		return fmt.Errorf("failed to create session: %w", err)
+		return nil, err
 	}
-	defer session.Close()
+	conn.client = client
+	return client, nil
+}
 
-	// Capture both stdout and stderr
-	var stdout, stderr bytes.Buffer
-	session.Stdout = &stdout
-	session.Stderr = &stderr
+// dial connects and authenticates to the host, giving up when the dial
+// timeout expires or the context is done
+func (s *SSHManager) dial(ctx context.Context, host string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	addr := net.JoinHostPort(host, "22")
+	dialer := net.Dialer{Timeout: s.config.DialTimeout}
+	netConn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial: %w", err)
+	}
 
-	// Build command that sources profile files if they exist
-	cmd := fmt.Sprintf(`
-if [ -f "$HOME/.bashrc" ]; then
-    source "$HOME/.bashrc"
-elif [ -f "$HOME/.bash_profile" ]; then
-    source "$HOME/.bash_profile"
-fi
+	// The handshake does not take a context, bound it through the connection
+	if err := netConn.SetDeadline(time.Now().Add(s.config.DialTimeout)); err != nil {
+		netConn.Close()
+		return nil, fmt.Errorf("failed to set deadline: %w", err)
+	}
+	stop := context.AfterFunc(ctx, func() { netConn.Close() })
 
-# Ensure Go is in PATH
-if [ -d "/usr/local/go/bin" ]; then
-    export PATH=$PATH:/usr/local/go/bin
-fi
-if [ -d "$HOME/go/bin" ]; then
-    export PATH=$PATH:$HOME/go/bin
-fi
+	sshConn, chans, reqs, err := ssh.NewClientConn(netConn, addr, config)
+	if !stop() {
+		if err == nil {
+			sshConn.Close()
+		}
+		return nil, fmt.Errorf("failed to dial: %w", ctx.Err())
+	}
+	if err != nil {
+		netConn.Close()
+		return nil, fmt.Errorf("failed to dial: %w", err)
+	}
 
-%s`, command)
+	if err := netConn.SetDeadline(time.Time{}); err != nil {
+		sshConn.Close()
+		return nil, fmt.Errorf("failed to clear deadline: %w", err)
+	}
+	return ssh.NewClient(sshConn, chans, reqs), nil
+}
 
-	if err := session.Run(cmd); err != nil {
-		return fmt.Errorf("failed to execute command: %w\nstdout: %s\nstderr: %s", err, stdout.String(), stderr.String())
+// drop closes and forgets the pooled client of the host if it is still the
+// given one, so that the next call reconnects
+func (s *SSHManager) drop(host string, client *ssh.Client) {
+	s.mu.Lock()
+	conn, ok := s.conns[host]
+	s.mu.Unlock()
+	if !ok {
+		return
 	}
 
-	return nil
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	if conn.client == client {
+		conn.client.Close()
+		conn.client = nil
+	}
 }
 
-// WriteToFile writes content to a file on a remote server
-func (s *SSHManager) WriteToFile(host, path, content string) error {
-	// Escape single quotes in content
-	escapedContent := strings.ReplaceAll(content, "'", "'\"'\"'")
-	command := fmt.Sprintf("echo '%s' > %s", escapedContent, path)
+// dropHost closes and forgets the pooled client of the host
+func (s *SSHManager) dropHost(host string) {
+	s.mu.Lock()
+	conn, ok := s.conns[host]
+	delete(s.conns, host)
+	s.mu.Unlock()
+	if !ok {
+		return
+	}
 
-	// Read private key
-	key, err := os.ReadFile(s.config.PrivateKey)
-	if err != nil {
-		return fmt.Errorf("failed to read private key from %s: %w", s.config.PrivateKey, err)
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	if conn.client != nil {
+		conn.client.Close()
+		conn.client = nil
 	}
+}
 
-	// Create signer
-	signer, err := ssh.ParsePrivateKey(key)
+// newSession opens a session on the pooled connection of the host. A broken
+// connection is replaced by a new one once.
+func (s *SSHManager) newSession(ctx context.Context, host string) (*ssh.Session, *ssh.Client, error) {
+	client, err := s.client(ctx, host)
 	if err != nil {
-		return fmt.Errorf("failed to parse private key: %w", err)
+		return nil, nil, err
 	}
 
-	// SSH client config
-	config := &ssh.ClientConfig{
-		User: s.config.Username,
-		Auth: []ssh.AuthMethod{
-			ssh.PublicKeys(signer),
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	session, err := client.NewSession()
+	if err == nil {
+		return session, client, nil
 	}
 
-	// Connect to server
-	client, err := ssh.Dial("tcp", host+":22", config)
+	// The connection went away, e.g. the host rebooted or the connection idled out
+	s.drop(host, client)
+	client, err = s.client(ctx, host)
 	if err != nil {
-		return fmt.Errorf("failed to dial: %w", err)
+		return nil, nil, err
 	}
-	defer client.Close()
+	session, err = client.NewSession()
+	if err != nil {
+		s.drop(host, client)
+		return nil, nil, fmt.Errorf("failed to create session: %w", err)
+	}
+	return session, client, nil
+}
+
+// CommandResult is the outcome of a command executed on a remote server
+type CommandResult struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+	Duration time.Duration
+}
+
+// RunOptions configures how RunCommand executes a command
+type RunOptions struct {
+	// Stream writes every line of output to the console while the command
+	// runs, prefixed with Prefix
+	Stream bool
+	// Prefix identifies the host in streamed output, e.g. the instance name
+	Prefix string
+}
+
+// CommandError is returned when a remote command exits with a non-zero status
+type CommandError struct {
+	Host   string
+	Result CommandResult
+}
+
+// Error implements the error interface
+func (e *CommandError) Error() string {
+	return fmt.Sprintf("command on %s exited with status %d\nstdout: %s\nstderr: %s", e.Host, e.Result.ExitCode, e.Result.Stdout, e.Result.Stderr)
+}
+
+// CheckConnection connects and authenticates to the host unless a connection
+// is pooled already
+func (s *SSHManager) CheckConnection(ctx context.Context, host string) error {
+	_, err := s.client(ctx, host)
+	return err
+}
+
+// ExecuteCommand executes a command on a remote server via SSH
+func (s *SSHManager) ExecuteCommand(ctx context.Context, host string, command string) error {
+	_, err := s.ExecuteCommandWithOutput(ctx, host, command)
+	return err
+}
+
+// ExecuteCommandWithOutput executes a command on a remote server via SSH and
+// returns its standard output
+func (s *SSHManager) ExecuteCommandWithOutput(ctx context.Context, host string, command string) (string, error) {
+	result, err := s.RunCommand(ctx, host, command, RunOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to execute command: %w", err)
+	}
+	return result.Stdout, nil
+}
+
+// RunCommand executes a command on a remote server via SSH. The result is
+// also returned when the command exits with a non-zero status, together with
+// a *CommandError. When the context is done or the command timeout expires,
+// the remote command is sent SIGTERM and its session is closed.
+func (s *SSHManager) RunCommand(ctx context.Context, host string, command string, opts RunOptions) (CommandResult, error) {
+	if s.config.CommandTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.config.CommandTimeout)
+		defer cancel()
+	}
+	startTime := time.Now()
 
 	// Create session
-	session, err := client.NewSession()
+	session, client, err := s.newSession(ctx, host)
 	if err != nil {
-		return fmt.Errorf("failed to create session: %w", err)
+		return CommandResult{ExitCode: -1}, err
 	}
 	defer session.Close()
 
-	// Capture both stdout and stderr
+	// Interrupt the remote command when the context is done
+	stop := context.AfterFunc(ctx, func() {
+		session.Signal(ssh.SIGTERM)
+		session.Close()
+	})
+	defer stop()
+
+	// Capture both stdout and stderr, streaming them if requested
 	var stdout, stderr bytes.Buffer
 	session.Stdout = &stdout
 	session.Stderr = &stderr
+	if opts.Stream {
+		stdoutLines := newPrefixWriter(os.Stdout, opts.Prefix)
+		stderrLines := newPrefixWriter(os.Stderr, opts.Prefix)
+		defer stdoutLines.Flush()
+		defer stderrLines.Flush()
+		session.Stdout = io.MultiWriter(&stdout, stdoutLines)
+		session.Stderr = io.MultiWriter(&stderr, stderrLines)
+	}
 
-	// Execute command with profile sourcing
+	// Build command that sources profile files if they exist
 	cmd := fmt.Sprintf(`
 if [ -f "$HOME/.bashrc" ]; then
     source "$HOME/.bashrc"
@@ -173,67 +370,212 @@ fi
 
 %s`, command)
 
-	if err := session.Run(cmd); err != nil {
-		return fmt.Errorf("failed to execute command: %w\nstdout: %s\nstderr: %s", err, stdout.String(), stderr.String())
+	err = session.Run(cmd)
+	result := CommandResult{
+		Stdout:   stdout.String(),
+		Stderr:   stderr.String(),
+		Duration: time.Since(startTime),
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			result.ExitCode = -1
+			return result, fmt.Errorf("command on %s interrupted: %w", host, ctx.Err())
+		}
+
+		var exitErr *ssh.ExitError
+		if errors.As(err, &exitErr) {
+			result.ExitCode = exitErr.ExitStatus()
+			return result, &CommandError{Host: host, Result: result}
+		}
+
+		// Anything but a non-zero exit status means the connection is unusable
+		s.drop(host, client)
+		result.ExitCode = -1
+		return result, fmt.Errorf("failed to run command on %s: %w\nstdout: %s\nstderr: %s", host, err, result.Stdout, result.Stderr)
 	}
 
-	return nil
+	return result, nil
 }
 
-// CopyFile copies a local file to a remote machine
-// TODO: use scp instead
-func (s *SSHManager) CopyFile(host, localPath, remotePath string) error {
-	// Read private key
-	key, err := os.ReadFile(s.config.PrivateKey)
+// WriteToFile writes content to a file with the given mode on a remote server
+func (s *SSHManager) WriteToFile(ctx context.Context, host, remotePath, content string, mode os.FileMode) error {
+	return s.withSFTP(ctx, host, func(client *sftp.Client) error {
+		return s.upload(ctx, client, host, strings.NewReader(content), sftpPath(remotePath), mode)
+	})
+}
+
+// CopyFile copies a local file to a remote machine over SFTP. The file keeps
+// its local permissions and replaces the destination atomically once its
+// SHA-256 checksum has been verified.
+func (s *SSHManager) CopyFile(ctx context.Context, host, localPath, remotePath string) error {
+	file, err := os.Open(localPath)
 	if err != nil {
-		return fmt.Errorf("failed to read private key from %s: %w", s.config.PrivateKey, err)
+		return fmt.Errorf("failed to open local file %s: %w", localPath, err)
 	}
+	defer file.Close()
 
-	// Create signer
-	signer, err := ssh.ParsePrivateKey(key)
+	info, err := file.Stat()
 	if err != nil {
-		return fmt.Errorf("failed to parse private key: %w", err)
+		return fmt.Errorf("failed to stat local file %s: %w", localPath, err)
 	}
 
-	// SSH client config
-	config := &ssh.ClientConfig{
-		User: s.config.Username,
-		Auth: []ssh.AuthMethod{
-			ssh.PublicKeys(signer),
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(), // Note: In production, use proper host key verification
+	return s.withSFTP(ctx, host, func(client *sftp.Client) error {
+		return s.upload(ctx, client, host, file, sftpPath(remotePath), info.Mode().Perm())
+	})
+}
+
+// DownloadFile copies a file from a remote machine to a local path. The file
+// keeps its remote permissions and replaces the local file atomically once its
+// SHA-256 checksum has been verified.
+func (s *SSHManager) DownloadFile(ctx context.Context, host, remotePath, localPath string) error {
+	return s.withSFTP(ctx, host, func(client *sftp.Client) error {
+		remotePath = sftpPath(remotePath)
+
+		remoteFile, err := client.Open(remotePath)
+		if err != nil {
+			return fmt.Errorf("failed to open remote file %s: %w", remotePath, err)
+		}
+		defer remoteFile.Close()
+
+		info, err := remoteFile.Stat()
+		if err != nil {
+			return fmt.Errorf("failed to stat remote file %s: %w", remotePath, err)
+		}
+
+		// Download into a temporary file next to the destination
+		tmpFile, err := os.CreateTemp(filepath.Dir(localPath), "."+filepath.Base(localPath)+".*.tmp")
+		if err != nil {
+			return fmt.Errorf("failed to create temporary file for %s: %w", localPath, err)
+		}
+		tmpPath := tmpFile.Name()
+		defer os.Remove(tmpPath)
+
+		hash := sha256.New()
+		if _, err := io.Copy(io.MultiWriter(tmpFile, hash), remoteFile); err != nil {
+			tmpFile.Close()
+			return fmt.Errorf("failed to download %s: %w", remotePath, err)
+		}
+		if err := tmpFile.Close(); err != nil {
+			return fmt.Errorf("failed to write %s: %w", tmpPath, err)
+		}
+
+		if err := s.verifyChecksum(ctx, host, remotePath, hex.EncodeToString(hash.Sum(nil))); err != nil {
+			return err
+		}
+
+		if err := os.Chmod(tmpPath, info.Mode().Perm()); err != nil {
+			return fmt.Errorf("failed to set mode of %s: %w", localPath, err)
+		}
+		if err := os.Rename(tmpPath, localPath); err != nil {
+			return fmt.Errorf("failed to move file to final destination: %w", err)
+		}
+		return nil
+	})
+}
+
+// withSFTP runs fn with an SFTP client on the pooled connection of the host.
+// The SFTP session is closed when the context is done, failing the transfer.
+func (s *SSHManager) withSFTP(ctx context.Context, host string, fn func(*sftp.Client) error) error {
+	sshClient, err := s.client(ctx, host)
+	if err != nil {
+		return err
 	}
 
-	// Connect to server
-	client, err := ssh.Dial("tcp", host+":22", config)
+	client, err := sftp.NewClient(sshClient)
 	if err != nil {
-		return fmt.Errorf("failed to dial: %w", err)
+		// The connection went away, reconnect once
+		s.drop(host, sshClient)
+		sshClient, err = s.client(ctx, host)
+		if err != nil {
+			return err
+		}
+		client, err = sftp.NewClient(sshClient)
+		if err != nil {
+			s.drop(host, sshClient)
+			return fmt.Errorf("failed to start sftp session: %w", err)
+		}
 	}
 	defer client.Close()
 
-	// Create session
-	session, err := client.NewSession()
-	if err != nil {
-		return fmt.Errorf("failed to create session: %w", err)
+	stop := context.AfterFunc(ctx, func() { client.Close() })
+	defer stop()
+
+	if err := fn(client); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("transfer to %s interrupted: %w", host, ctx.Err())
+		}
+		return err
 	}
-	defer session.Close()
+	return nil
+}
+
+// upload writes r to a temporary file next to remotePath, verifies its
+// checksum and renames it into place
+func (s *SSHManager) upload(ctx context.Context, client *sftp.Client, host string, r io.Reader, remotePath string, mode os.FileMode) error {
+	tmpPath := fmt.Sprintf("%s.%d.tmp", remotePath, time.Now().UnixNano())
 
-	// Read the local file
-	content, err := os.ReadFile(localPath)
+	tmpFile, err := client.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
 	if err != nil {
-		return fmt.Errorf("failed to read local file %s: %w", localPath, err)
+		return fmt.Errorf("failed to create temporary file %s: %w", tmpPath, err)
+	}
+
+	// Set the mode before writing, the remote umask applies on creation and
+	// secrets must not be readable by others at any point
+	if err := tmpFile.Chmod(mode); err != nil {
+		tmpFile.Close()
+		client.Remove(tmpPath)
+		return fmt.Errorf("failed to set mode of %s: %w", tmpPath, err)
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(tmpFile, io.TeeReader(r, hash)); err != nil {
+		tmpFile.Close()
+		client.Remove(tmpPath)
+		return fmt.Errorf("failed to upload %s: %w", remotePath, err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		client.Remove(tmpPath)
+		return fmt.Errorf("failed to write %s: %w", tmpPath, err)
 	}
 
-	// Create a temporary file on the remote machine
-	tempPath := fmt.Sprintf("/tmp/%s", filepath.Base(localPath))
-	if err := s.WriteToFile(host, tempPath, string(content)); err != nil {
-		return fmt.Errorf("failed to write temporary file on remote machine: %w", err)
+	if err := s.verifyChecksum(ctx, host, tmpPath, hex.EncodeToString(hash.Sum(nil))); err != nil {
+		client.Remove(tmpPath)
+		return err
 	}
 
-	// Move the temporary file to the final destination
-	if err := s.ExecuteCommand(host, fmt.Sprintf("mv %s %s", tempPath, remotePath)); err != nil {
+	if err := client.PosixRename(tmpPath, remotePath); err != nil {
+		client.Remove(tmpPath)
 		return fmt.Errorf("failed to move file to final destination: %w", err)
 	}
 
 	return nil
 }
+
+// verifyChecksum compares the SHA-256 checksum of a remote file with the
+// expected hex encoded checksum
+func (s *SSHManager) verifyChecksum(ctx context.Context, host, remotePath, expected string) error {
+	output, err := s.ExecuteCommandWithOutput(ctx, host, fmt.Sprintf("sha256sum %s", shellQuote(remotePath)))
+	if err != nil {
+		return fmt.Errorf("failed to compute checksum of %s: %w", remotePath, err)
+	}
+
+	fields := strings.Fields(output)
+	if len(fields) == 0 || fields[0] != expected {
+		return fmt.Errorf("checksum mismatch for %s on %s: expected %s, got %q", remotePath, host, expected, strings.TrimSpace(output))
+	}
+	return nil
+}
+
+// sftpPath converts a shell style remote path to one SFTP understands.
+// Relative paths are resolved against the home directory by the server.
+func sftpPath(remotePath string) string {
+	if remotePath == "~" {
+		return "."
+	}
+	return strings.TrimPrefix(remotePath, "~/")
+}
+
+// shellQuote quotes s for use as a single word in a POSIX shell command
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "'\"'\"'") + "'"
+}
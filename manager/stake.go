@@ -0,0 +1,153 @@
+package manager
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"text/tabwriter"
+
+	"github.com/celestiaorg/talis-test/config"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+const (
+	// defaultPowerLawExponent is the exponent of the power-law distribution
+	// if none is configured
+	defaultPowerLawExponent = 1.0
+	// defaultWhaleShare is the share of the total stake the whale gets if
+	// none is configured
+	defaultWhaleShare = 0.5
+)
+
+// validatorTokens are the stake and the initial balance of a genesis
+// validator in utia
+type validatorTokens struct {
+	stake   int64
+	balance int64
+}
+
+// genesisTokens returns the tokens of the genesis validator instances.
+// Validators with a stake in their definition keep it, the others share the
+// stake of the configured distribution in the order of names. Validators
+// without a balance get the default balance, or their stake plus the default
+// balance if the stake is larger.
+func (m *TalisManager) genesisTokens(names []string) (map[string]validatorTokens, error) {
+	tokens := make(map[string]validatorTokens, len(names))
+	var distributed []string
+	for _, name := range names {
+		def, _ := m.configuredDefinition(name)
+		tokens[name] = validatorTokens{stake: def.Stake, balance: def.Balance}
+		if def.Stake == 0 {
+			distributed = append(distributed, name)
+		}
+	}
+
+	stakes, err := distributeStake(m.config.Genesis.Stake, len(distributed))
+	if err != nil {
+		return nil, err
+	}
+	for i, name := range distributed {
+		t := tokens[name]
+		t.stake = stakes[i]
+		tokens[name] = t
+	}
+
+	powerReduction := sdk.DefaultPowerReduction.Int64()
+	for _, name := range names {
+		t := tokens[name]
+		if t.stake < powerReduction {
+			return nil, fmt.Errorf("stake of validator %s is %d utia, less than the %d utia of one unit of voting power", name, t.stake, powerReduction)
+		}
+		if t.balance == 0 {
+			t.balance = validatorBalance
+			if t.stake > validatorBalance {
+				t.balance = t.stake + validatorBalance
+			}
+		}
+		if t.stake > t.balance {
+			return nil, fmt.Errorf("stake of validator %s is %d utia, more than its balance of %d utia", name, t.stake, t.balance)
+		}
+		tokens[name] = t
+	}
+
+	return tokens, nil
+}
+
+// distributeStake splits the total stake of a distribution among n
+// validators. The first validator gets the rounding remainder.
+func distributeStake(distribution config.StakeDistribution, n int) ([]int64, error) {
+	if n == 0 {
+		return nil, nil
+	}
+
+	total := distribution.Total
+	if total == 0 {
+		total = validatorStake * int64(n)
+	}
+
+	weights := make([]float64, n)
+	switch distribution.Type {
+	case "", config.DistributionEqual:
+		for i := range weights {
+			weights[i] = 1
+		}
+	case config.DistributionPowerLaw:
+		exponent := distribution.Exponent
+		if exponent == 0 {
+			exponent = defaultPowerLawExponent
+		}
+		for i := range weights {
+			weights[i] = 1 / math.Pow(float64(i+1), exponent)
+		}
+	case config.DistributionWhale:
+		share := distribution.WhaleShare
+		if share == 0 {
+			share = defaultWhaleShare
+		}
+		weights[0] = 1
+		if n > 1 {
+			weights[0] = share
+			for i := 1; i < n; i++ {
+				weights[i] = (1 - share) / float64(n-1)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown stake distribution %q", distribution.Type)
+	}
+
+	var sum float64
+	for _, weight := range weights {
+		sum += weight
+	}
+
+	stakes := make([]int64, n)
+	var assigned int64
+	for i, weight := range weights {
+		stakes[i] = int64(float64(total) * weight / sum)
+		assigned += stakes[i]
+	}
+	stakes[0] += total - assigned
+
+	return stakes, nil
+}
+
+// printVotingPower prints the stake, balance and voting power of the genesis
+// validators
+func printVotingPower(names []string, nodeNames map[string]string, tokens map[string]validatorTokens) error {
+	powerReduction := sdk.DefaultPowerReduction.Int64()
+	var totalPower int64
+	for _, name := range names {
+		totalPower += tokens[name].stake / powerReduction
+	}
+
+	fmt.Println("Genesis voting power:")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "INSTANCE\tNODE\tSTAKE (UTIA)\tBALANCE (UTIA)\tVOTING POWER\tSHARE")
+	for _, name := range names {
+		t := tokens[name]
+		power := t.stake / powerReduction
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%.2f%%\n",
+			name, nodeNames[name], t.stake, t.balance, power, 100*float64(power)/float64(totalPower))
+	}
+	return w.Flush()
+}
@@ -0,0 +1,181 @@
+package manager
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/celestiaorg/talis-test/config"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestDistributeStake(t *testing.T) {
+	tests := []struct {
+		name         string
+		distribution config.StakeDistribution
+		n            int
+		want         []int64
+		wantErr      string
+	}{
+		{
+			name: "no validators",
+			n:    0,
+		},
+		{
+			name: "equal by default",
+			n:    3,
+			want: []int64{validatorStake, validatorStake, validatorStake},
+		},
+		{
+			name:         "equal with remainder",
+			distribution: config.StakeDistribution{Type: config.DistributionEqual, Total: 10},
+			n:            3,
+			want:         []int64{4, 3, 3},
+		},
+		{
+			name:         "power law",
+			distribution: config.StakeDistribution{Type: config.DistributionPowerLaw},
+			n:            3,
+			want:         []int64{1636363636365, 818181818181, 545454545454},
+		},
+		{
+			name:         "power law with exponent",
+			distribution: config.StakeDistribution{Type: config.DistributionPowerLaw, Total: 1000000, Exponent: 2},
+			n:            3,
+			want:         []int64{734695, 183673, 81632},
+		},
+		{
+			name:         "single whale",
+			distribution: config.StakeDistribution{Type: config.DistributionWhale, Total: 1000, WhaleShare: 0.6},
+			n:            1,
+			want:         []int64{1000},
+		},
+		{
+			name:         "whale",
+			distribution: config.StakeDistribution{Type: config.DistributionWhale, Total: 1000, WhaleShare: 0.6},
+			n:            3,
+			want:         []int64{600, 200, 200},
+		},
+		{
+			name:         "whale with default share",
+			distribution: config.StakeDistribution{Type: config.DistributionWhale, Total: 1000},
+			n:            3,
+			want:         []int64{500, 250, 250},
+		},
+		{
+			name:         "unknown distribution",
+			distribution: config.StakeDistribution{Type: "pareto"},
+			n:            3,
+			wantErr:      `unknown stake distribution "pareto"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := distributeStake(tt.distribution, tt.n)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("stakes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenesisTokens(t *testing.T) {
+	powerReduction := sdk.DefaultPowerReduction.Int64()
+	validator := func(name string) config.InstanceDefinition {
+		return config.NewInstanceDefinition(name, true, false).WithRole(config.ValidatorNode)
+	}
+
+	tests := []struct {
+		name        string
+		definitions []config.InstanceDefinition
+		stake       config.StakeDistribution
+		want        map[string]validatorTokens
+		wantErr     string
+	}{
+		{
+			name:        "defaults",
+			definitions: []config.InstanceDefinition{validator("validator-1"), validator("validator-2")},
+			want: map[string]validatorTokens{
+				"validator-1-0": {stake: validatorStake, balance: validatorBalance},
+				"validator-2-0": {stake: validatorStake, balance: validatorBalance},
+			},
+		},
+		{
+			name: "configured stake is not distributed",
+			definitions: []config.InstanceDefinition{
+				validator("validator-1").WithTokens(5*powerReduction, 0),
+				validator("validator-2"),
+				validator("validator-3"),
+			},
+			stake: config.StakeDistribution{Type: config.DistributionWhale, Total: 10 * powerReduction, WhaleShare: 0.8},
+			want: map[string]validatorTokens{
+				"validator-1-0": {stake: 5 * powerReduction, balance: validatorBalance},
+				"validator-2-0": {stake: 8 * powerReduction, balance: validatorBalance},
+				"validator-3-0": {stake: 2 * powerReduction, balance: validatorBalance},
+			},
+		},
+		{
+			name:        "stake above the default balance",
+			definitions: []config.InstanceDefinition{validator("validator-1").WithTokens(2*validatorBalance, 0)},
+			want: map[string]validatorTokens{
+				"validator-1-0": {stake: 2 * validatorBalance, balance: 3 * validatorBalance},
+			},
+		},
+		{
+			name:        "configured balance",
+			definitions: []config.InstanceDefinition{validator("validator-1").WithTokens(powerReduction, 2*powerReduction)},
+			want: map[string]validatorTokens{
+				"validator-1-0": {stake: powerReduction, balance: 2 * powerReduction},
+			},
+		},
+		{
+			name:        "stake below one voting power",
+			definitions: []config.InstanceDefinition{validator("validator-1").WithTokens(powerReduction-1, 0)},
+			wantErr:     "less than the",
+		},
+		{
+			name:        "distributed stake below one voting power",
+			definitions: []config.InstanceDefinition{validator("validator-1"), validator("validator-2")},
+			stake:       config.StakeDistribution{Total: powerReduction},
+			wantErr:     "stake of validator validator-1-0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager(t, NewFakeProvider())
+			m.config.Instances = tt.definitions
+			m.config.Genesis.Stake = tt.stake
+
+			var names []string
+			for _, def := range tt.definitions {
+				names = append(names, instanceName(def))
+			}
+			got, err := m.genesisTokens(names)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for name, want := range tt.want {
+				if got[name] != want {
+					t.Errorf("tokens of %s = %+v, want %+v", name, got[name], want)
+				}
+			}
+		})
+	}
+}
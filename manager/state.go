@@ -2,38 +2,174 @@ package manager
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"time"
+
+	"github.com/celestiaorg/talis-test/config"
+	"github.com/gofrs/flock"
+)
+
+// stateVersion is the current version of the state schema. LoadState
+// migrates older state files to it.
+const stateVersion = 2
+
+// Stage is a deployment step an instance has gone through
+type Stage string
+
+const (
+	// StageProvisioned is recorded once the instance is ready and has a public IP
+	StageProvisioned Stage = "provisioned"
+	// StageGoInstalled is recorded once Go is installed
+	StageGoInstalled Stage = "go_installed"
+	// StageAppInstalled is recorded once Celestia App is installed
+	StageAppInstalled Stage = "app_installed"
+	// StageNodeInstalled is recorded once Celestia Node is installed
+	StageNodeInstalled Stage = "node_installed"
+	// StageGenesis is recorded once keys, genesis and configs are distributed
+	StageGenesis Stage = "genesis"
+	// StageStarted is recorded while the node service is running
+	StageStarted Stage = "started"
+	// StageJoined is recorded once a validator added after genesis is bonded
+	StageJoined Stage = "joined"
 )
 
 // InstanceInfo represents information about an instance
 type InstanceInfo struct {
-	ID       uint   `json:"id"`
-	Name     string `json:"name"`
-	PublicIP string `json:"public_ip"`
-	// Installation preferences are now determined at runtime from the configuration
-	// rather than stored in the state
+	ID       uint            `json:"id"`
+	Name     string          `json:"name"`
+	PublicIP string          `json:"public_ip"`
+	Role     config.NodeType `json:"role,omitempty"`
+	Region   string          `json:"region,omitempty"`
+	Size     string          `json:"size,omitempty"`
+	// Definition is the configuration the instance was created from
+	Definition config.InstanceDefinition `json:"definition"`
+	CreatedAt  time.Time                 `json:"created_at,omitempty"`
+	// Stages maps the completed deployment stages to their completion time
+	Stages map[Stage]time.Time `json:"stages,omitempty"`
+	// ValidatorPubKey is the base64 encoded consensus public key of validators
+	ValidatorPubKey string `json:"validator_pub_key,omitempty"`
+	// NetworkPubKey is the base64 encoded public key of the node's P2P identity
+	NetworkPubKey string `json:"network_pub_key,omitempty"`
+	// KeyName is the name of the validator's account key in the test keyring
+	// of the instance
+	KeyName string `json:"key_name,omitempty"`
+}
+
+// HasStage reports whether the instance completed the given stage
+func (i InstanceInfo) HasStage(stage Stage) bool {
+	_, ok := i.Stages[stage]
+	return ok
+}
+
+// setStage records the completion of a stage
+func (i *InstanceInfo) setStage(stage Stage) {
+	if i.Stages == nil {
+		i.Stages = make(map[Stage]time.Time)
+	}
+	i.Stages[stage] = time.Now().UTC()
 }
 
 // State represents the persisted state of the application
 type State struct {
+	Version   int                       `json:"version"`
 	UserID    uint                      `json:"user_id"`
 	Projects  map[string]string         `json:"projects"`  // Map of project name to project ID
 	Instances map[string][]InstanceInfo `json:"instances"` // Map of project name to instance info
 }
 
-// getStatePath returns the path to the state file
-func getStatePath() (string, error) {
-	homeDir, err := os.UserHomeDir()
+// statePath returns the path to the state file of the configured environment
+func (m *TalisManager) statePath() (string, error) {
+	dir, err := EnvironmentDir(m.config.Environment)
 	if err != nil {
 		return "", err
 	}
-	return filepath.Join(homeDir, ".talis-test", "state.json"), nil
+	return filepath.Join(dir, "state.json"), nil
+}
+
+// KnownHostsPath returns the path to the known_hosts file holding the host
+// keys of the instances. It is shared by all environments.
+func KnownHostsPath() (string, error) {
+	dir, err := baseDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "known_hosts"), nil
+}
+
+// updateInstance applies fn to the named instance of the current project and
+// saves the state. It is safe to call from concurrent stage workers.
+func (m *TalisManager) updateInstance(name string, fn func(*InstanceInfo)) error {
+	m.stateMu.Lock()
+	defer m.stateMu.Unlock()
+
+	instances := m.state.Instances[m.config.ProjectName]
+	for i := range instances {
+		if instances[i].Name == name {
+			fn(&instances[i])
+			return m.SaveState(m.state)
+		}
+	}
+	return fmt.Errorf("instance %s not found in state", name)
+}
+
+// markStage records in state that the named instance completed the stage
+func (m *TalisManager) markStage(name string, stage Stage) error {
+	return m.updateInstance(name, func(inst *InstanceInfo) {
+		inst.setStage(stage)
+	})
+}
+
+// stateBackups is the number of previous state versions kept next to the
+// state file, one per command that modified the state
+const stateBackups = 5
+
+// LockState acquires the advisory lock on the state file and keeps a backup of
+// the current state. Commands that modify the state hold the lock until Close.
+func (m *TalisManager) LockState() error {
+	statePath, err := m.statePath()
+	if err != nil {
+		return err
+	}
+
+	// Create directory if it doesn't exist
+	if err := os.MkdirAll(filepath.Dir(statePath), 0755); err != nil {
+		return err
+	}
+
+	lock := flock.New(statePath + ".lock")
+	locked, err := lock.TryLock()
+	if err != nil {
+		return fmt.Errorf("failed to lock state %s: %w", statePath, err)
+	}
+	if !locked {
+		return fmt.Errorf("state %s is in use by another command, wait for it to finish", statePath)
+	}
+
+	if err := backupState(statePath); err != nil {
+		lock.Unlock()
+		return fmt.Errorf("failed to back up state: %w", err)
+	}
+
+	m.stateLock = lock
+	return nil
 }
 
-// SaveState saves the current state to a file
+// unlockState releases the state lock if it is held
+func (m *TalisManager) unlockState() error {
+	if m.stateLock == nil {
+		return nil
+	}
+	err := m.stateLock.Unlock()
+	m.stateLock = nil
+	return err
+}
+
+// SaveState saves the current state to a file. The file is replaced
+// atomically so that an interrupted write never leaves a truncated state.
 func (m *TalisManager) SaveState(state State) error {
-	statePath, err := getStatePath()
+	statePath, err := m.statePath()
 	if err != nil {
 		return err
 	}
@@ -43,17 +179,77 @@ func (m *TalisManager) SaveState(state State) error {
 		return err
 	}
 
+	state.Version = stateVersion
 	data, err := json.MarshalIndent(state, "", "  ")
 	if err != nil {
 		return err
 	}
 
-	return os.WriteFile(statePath, data, 0644)
+	return writeFileAtomic(statePath, data, 0644)
+}
+
+// backupState rotates the backups of the state file and copies the current
+// state to the first one
+func backupState(statePath string) error {
+	data, err := os.ReadFile(statePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	for i := stateBackups; i > 1; i-- {
+		err := os.Rename(fmt.Sprintf("%s.%d", statePath, i-1), fmt.Sprintf("%s.%d", statePath, i))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return writeFileAtomic(statePath+".1", data, 0644)
+}
+
+// writeFileAtomic writes data to a temporary file in the same directory,
+// syncs it and renames it over path
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	tmpFile, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmpFile.Name()
+	defer os.Remove(tmpPath)
+
+	if _, err := tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+
+	// Sync the directory so that the rename itself is durable
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
 }
 
 // LoadState loads the state from a file
 func (m *TalisManager) LoadState() (State, error) {
-	statePath, err := getStatePath()
+	statePath, err := m.statePath()
 	if err != nil {
 		return State{}, err
 	}
@@ -62,6 +258,7 @@ func (m *TalisManager) LoadState() (State, error) {
 	if err != nil {
 		if os.IsNotExist(err) {
 			return State{
+				Version:   stateVersion,
 				Projects:  make(map[string]string),
 				Instances: make(map[string][]InstanceInfo),
 			}, nil
@@ -71,7 +268,7 @@ func (m *TalisManager) LoadState() (State, error) {
 
 	var state State
 	if err := json.Unmarshal(data, &state); err != nil {
-		return State{}, err
+		return State{}, fmt.Errorf("failed to parse state %s, previous versions are kept in %s.1 to %s.%d: %w", statePath, statePath, statePath, stateBackups, err)
 	}
 
 	// Initialize maps if they're nil (for backward compatibility)
@@ -82,5 +279,9 @@ func (m *TalisManager) LoadState() (State, error) {
 		state.Instances = make(map[string][]InstanceInfo)
 	}
 
+	if err := m.migrateState(&state); err != nil {
+		return State{}, fmt.Errorf("failed to migrate state %s: %w", statePath, err)
+	}
+
 	return state, nil
 }
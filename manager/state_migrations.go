@@ -0,0 +1,55 @@
+package manager
+
+import (
+	"fmt"
+	"log"
+)
+
+// stateMigrations upgrade the state schema one version at a time. The
+// migration at index i upgrades version i+1 to version i+2.
+var stateMigrations = []func(m *TalisManager, state *State){
+	(*TalisManager).migrateStateV1,
+}
+
+// migrateState upgrades the state to the current schema version. State files
+// written before the schema was versioned are version 1.
+func (m *TalisManager) migrateState(state *State) error {
+	if state.Version == 0 {
+		state.Version = 1
+	}
+	if state.Version > stateVersion {
+		return fmt.Errorf("state version %d is newer than the supported version %d, upgrade talis-test", state.Version, stateVersion)
+	}
+
+	for state.Version < stateVersion {
+		log.Printf("Migrating state from version %d to %d", state.Version, state.Version+1)
+		stateMigrations[state.Version-1](m, state)
+		state.Version++
+	}
+	return nil
+}
+
+// migrateStateV1 fills in the deployment metadata that version 1 did not
+// record. Version 1 matched instances to the configuration by their position,
+// so the definitions are recovered the same way for the current project.
+func (m *TalisManager) migrateStateV1(state *State) {
+	for project, instances := range state.Instances {
+		for i := range instances {
+			inst := &instances[i]
+			if inst.PublicIP != "" {
+				inst.setStage(StageProvisioned)
+			}
+
+			if project != m.config.ProjectName || i >= len(m.config.Instances) {
+				log.Printf("No configuration for instance %s of project %s, its role is unknown", inst.Name, project)
+				continue
+			}
+
+			def := m.config.Instances[i]
+			inst.Definition = def
+			inst.Role = def.Role
+			inst.Region = def.InstanceConfig.Region
+			inst.Size = def.InstanceConfig.Size
+		}
+	}
+}
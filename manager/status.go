@@ -0,0 +1,128 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/celestiaorg/talis-test/config"
+)
+
+// NodeStatus is the health of a single node of the deployment
+type NodeStatus struct {
+	Name         string          `json:"name"`
+	PublicIP     string          `json:"public_ip"`
+	Role         config.NodeType `json:"role"`
+	Service      string          `json:"service"`
+	ServiceState string          `json:"service_state"`
+	LatestHeight int64           `json:"latest_height"`
+	CatchingUp   bool            `json:"catching_up"`
+	Peers        int             `json:"peers"`
+	AppVersion   uint64          `json:"app_version"`
+	Error        string          `json:"error,omitempty"`
+}
+
+// serviceForRole returns the systemd service that runs a node of the given role
+func serviceForRole(role config.NodeType) string {
+	switch role {
+	case config.BridgeNode:
+		return "celestia-bridge"
+	case config.LightNode:
+		return "celestia-light"
+	default:
+		return "celestia-appd"
+	}
+}
+
+// Status reports the service state of every instance and, for consensus
+// nodes, their chain health as seen by the CometBFT RPC
+func (m *TalisManager) Status(ctx context.Context) ([]NodeStatus, error) {
+	// Load state
+	state, err := m.LoadState()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load state: %w", err)
+	}
+	m.state = state
+
+	instances := m.state.Instances[m.config.ProjectName]
+	statuses := make([]NodeStatus, len(instances))
+
+	// Create a semaphore to limit concurrent operations
+	sem := make(chan struct{}, 10)
+	var wg sync.WaitGroup
+
+	for i, instance := range instances {
+		statuses[i] = NodeStatus{Name: instance.Name, PublicIP: instance.PublicIP, Role: instance.Role}
+		statuses[i].Service = serviceForRole(statuses[i].Role)
+
+		if instance.PublicIP == "" {
+			statuses[i].Error = "no public IP"
+			continue
+		}
+
+		wg.Add(1)
+		go func(status *NodeStatus) {
+			defer wg.Done()
+
+			// Acquire semaphore
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			m.nodeStatus(ctx, status)
+		}(&statuses[i])
+	}
+
+	wg.Wait()
+	return statuses, nil
+}
+
+// nodeStatus fills in the service state and chain health of a single node
+func (m *TalisManager) nodeStatus(ctx context.Context, status *NodeStatus) {
+	// is-active exits non-zero for inactive services, the state is on stdout
+	output, err := m.sshManager.ExecuteCommandWithOutput(ctx, status.PublicIP, fmt.Sprintf("systemctl is-active %s || true", status.Service))
+	if err != nil {
+		status.ServiceState = "unknown"
+		status.Error = strings.SplitN(err.Error(), "\n", 2)[0]
+	} else {
+		status.ServiceState = strings.TrimSpace(output)
+	}
+
+	// Only consensus nodes expose the CometBFT RPC
+	if status.Role == config.BridgeNode || status.Role == config.LightNode {
+		return
+	}
+
+	var cometStatus struct {
+		SyncInfo struct {
+			LatestBlockHeight int64 `json:"latest_block_height,string"`
+			CatchingUp        bool  `json:"catching_up"`
+		} `json:"sync_info"`
+	}
+	if err := rpcGet(ctx, status.PublicIP, "status", &cometStatus); err != nil {
+		status.Error = err.Error()
+		return
+	}
+	status.LatestHeight = cometStatus.SyncInfo.LatestBlockHeight
+	status.CatchingUp = cometStatus.SyncInfo.CatchingUp
+
+	var netInfo struct {
+		NPeers int `json:"n_peers,string"`
+	}
+	if err := rpcGet(ctx, status.PublicIP, "net_info", &netInfo); err != nil {
+		status.Error = err.Error()
+		return
+	}
+	status.Peers = netInfo.NPeers
+
+	var abciInfo struct {
+		Response struct {
+			AppVersion uint64 `json:"app_version,string"`
+		} `json:"response"`
+	}
+	if err := rpcGet(ctx, status.PublicIP, "abci_info", &abciInfo); err != nil {
+		status.Error = err.Error()
+		return
+	}
+	status.AppVersion = abciInfo.Response.AppVersion
+}
@@ -0,0 +1,73 @@
+#!/bin/bash
+
+# Exit on error
+set -e
+
+# Usage: setup_celestia_bridge_service.sh <core_ip> <network> <genesis_hash>
+CORE_IP=$1
+NETWORK=$2
+GENESIS_HASH=$3
+NODE_STORE="$HOME/.celestia-bridge"
+
+if [ -z "$CORE_IP" ] || [ -z "$NETWORK" ] || [ -z "$GENESIS_HASH" ]; then
+    echo "Usage: $0 <core_ip> <network> <genesis_hash>"
+    exit 1
+fi
+
+# Resolve the celestia binary installed by install_celestia_node.sh
+CELESTIA_BIN=$(command -v celestia || true)
+if [ -z "$CELESTIA_BIN" ]; then
+    echo "celestia binary not found in PATH"
+    exit 1
+fi
+
+# Configure the custom private network
+export CELESTIA_CUSTOM="$NETWORK:$GENESIS_HASH"
+
+# Initialize the node store if it does not exist yet
+if [ ! -f "$NODE_STORE/config.toml" ]; then
+    echo "Initializing bridge node store in $NODE_STORE..."
+    $CELESTIA_BIN bridge init \
+        --core.ip "$CORE_IP" \
+        --p2p.network "$NETWORK" \
+        --node.store "$NODE_STORE"
+else
+    echo "Bridge node store already initialized in $NODE_STORE"
+fi
+
+# Get the current user
+USER=$(whoami)
+
+# Create the systemd service file, the core endpoint may have changed
+echo "Creating Celestia Bridge systemd service file..."
+sudo tee /etc/systemd/system/celestia-bridge.service > /dev/null << EOF
+[Unit]
+Description=celestia bridge node
+After=network-online.target
+
+[Service]
+User=$USER
+Environment=CELESTIA_CUSTOM=$CELESTIA_CUSTOM
+ExecStart=$CELESTIA_BIN bridge start --core.ip $CORE_IP --p2p.network $NETWORK --node.store $NODE_STORE
+Restart=on-failure
+RestartSec=3
+LimitNOFILE=1400000
+
+[Install]
+WantedBy=multi-user.target
+EOF
+
+# Reload systemd to recognize the new service
+echo "Reloading systemd..."
+sudo systemctl daemon-reload
+
+# Enable and (re)start the service
+echo "Enabling and starting celestia-bridge service..."
+sudo systemctl enable celestia-bridge
+sudo systemctl restart celestia-bridge
+
+# Check service status
+echo "Checking service status..."
+sudo systemctl status celestia-bridge --no-pager
+
+echo "Celestia Bridge node setup completed successfully!"
@@ -0,0 +1,73 @@
+#!/bin/bash
+
+# Exit on error
+set -e
+
+# Usage: setup_celestia_light_service.sh <network> <genesis_hash> <bootstrappers> <trusted_hash>
+NETWORK=$1
+GENESIS_HASH=$2
+BOOTSTRAPPERS=$3
+TRUSTED_HASH=$4
+NODE_STORE="$HOME/.celestia-light"
+
+if [ -z "$NETWORK" ] || [ -z "$GENESIS_HASH" ] || [ -z "$BOOTSTRAPPERS" ] || [ -z "$TRUSTED_HASH" ]; then
+    echo "Usage: $0 <network> <genesis_hash> <bootstrappers> <trusted_hash>"
+    exit 1
+fi
+
+# Resolve the celestia binary installed by install_celestia_node.sh
+CELESTIA_BIN=$(command -v celestia || true)
+if [ -z "$CELESTIA_BIN" ]; then
+    echo "celestia binary not found in PATH"
+    exit 1
+fi
+
+# Configure the custom private network with the bridges as bootstrappers
+export CELESTIA_CUSTOM="$NETWORK:$GENESIS_HASH:$BOOTSTRAPPERS"
+
+# Initialize the node store if it does not exist yet
+if [ ! -f "$NODE_STORE/config.toml" ]; then
+    echo "Initializing light node store in $NODE_STORE..."
+    $CELESTIA_BIN light init \
+        --p2p.network "$NETWORK" \
+        --node.store "$NODE_STORE"
+else
+    echo "Light node store already initialized in $NODE_STORE"
+fi
+
+# Get the current user
+USER=$(whoami)
+
+# Create the systemd service file, the bootstrappers may have changed
+echo "Creating Celestia Light systemd service file..."
+sudo tee /etc/systemd/system/celestia-light.service > /dev/null << EOF
+[Unit]
+Description=celestia light node
+After=network-online.target
+
+[Service]
+User=$USER
+Environment=CELESTIA_CUSTOM=$CELESTIA_CUSTOM
+ExecStart=$CELESTIA_BIN light start --p2p.network $NETWORK --node.store $NODE_STORE --headers.trusted-hash $TRUSTED_HASH
+Restart=on-failure
+RestartSec=3
+LimitNOFILE=1400000
+
+[Install]
+WantedBy=multi-user.target
+EOF
+
+# Reload systemd to recognize the new service
+echo "Reloading systemd..."
+sudo systemctl daemon-reload
+
+# Enable and (re)start the service
+echo "Enabling and starting celestia-light service..."
+sudo systemctl enable celestia-light
+sudo systemctl restart celestia-light
+
+# Check service status
+echo "Checking service status..."
+sudo systemctl status celestia-light --no-pager
+
+echo "Celestia Light node setup completed successfully!"